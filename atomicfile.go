@@ -0,0 +1,41 @@
+package vrest
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const privateDirMode = 0o700
+
+// writeFileAtomic writes the file to a temporary file first and renames it,
+// so readers never see a partial file. The file is only readable by the
+// current user. Missing directories are created.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, privateDirMode); err != nil {
+		return err
+	}
+
+	// CreateTemp creates the file with mode 0600
+	file, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
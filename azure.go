@@ -0,0 +1,103 @@
+package vrest
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultAzureIMDSEndpoint is the token endpoint of the Azure Instance Metadata Service.
+	DefaultAzureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// DefaultAzureIMDSAPIVersion is the default api-version of the IMDS token endpoint.
+	DefaultAzureIMDSAPIVersion = "2018-02-01"
+)
+
+var ErrAzureManagedIdentityFailed = errors.New("failed to get azure managed identity token")
+
+// AzureManagedIdentityConfig is the configuration for Azure managed identity tokens.
+type AzureManagedIdentityConfig struct {
+	// Endpoint defaults to DefaultAzureIMDSEndpoint.
+	Endpoint string
+
+	// APIVersion defaults to DefaultAzureIMDSAPIVersion.
+	APIVersion string
+
+	// Resource is the App ID URI of the target resource,
+	// for example https://management.azure.com/.
+	Resource string
+
+	// ClientID selects a user-assigned managed identity.
+	// If empty, the system-assigned identity is used.
+	ClientID string
+}
+
+type azureManagedIdentityGetter struct {
+	config AzureManagedIdentityConfig
+	client *Client
+}
+
+// azureIMDSToken is the token response of IMDS. The numbers are sent as strings.
+type azureIMDSToken struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	ExpiresOn   json.Number `json:"expires_on"`
+}
+
+// SetAzureManagedIdentity sets a token getter that requests tokens
+// of the Azure managed identity from the Instance Metadata Service.
+// Token scopes with a Resource request tokens for that resource.
+func (c *Client) SetAzureManagedIdentity(cfg AzureManagedIdentityConfig) *Client {
+	return c.SetTokenGetter(&azureManagedIdentityGetter{
+		config: cfg,
+		client: c,
+	})
+}
+
+// GetToken requests a token for the configured resource.
+func (g *azureManagedIdentityGetter) GetToken(ctx context.Context, oldToken Token) (Token, error) {
+	return g.GetScopedToken(ctx, TokenScope{}, oldToken)
+}
+
+// GetScopedToken requests a token for the resource of the scope.
+func (g *azureManagedIdentityGetter) GetScopedToken(ctx context.Context, scope TokenScope, _ Token) (Token, error) {
+	resource := cmp.Or(scope.Resource, g.config.Resource)
+	req := g.client.NewRequestWithContext(ctx).
+		SetBaseURL(cmp.Or(g.config.Endpoint, DefaultAzureIMDSEndpoint)).
+		SetTokenRequest().
+		SetHeader("Metadata", "true").
+		SetQueryParam("api-version", cmp.Or(g.config.APIVersion, DefaultAzureIMDSAPIVersion)).
+		SetQueryParamIf(resource != "", "resource", resource).
+		SetQueryParamIf(g.config.ClientID != "", "client_id", g.config.ClientID)
+
+	var resp azureIMDSToken
+	if err := req.SetResponseBody(&resp).DoGet(""); err != nil {
+		return nil, errors.Join(ErrAzureManagedIdentityFailed, err)
+	}
+	if resp.AccessToken == "" {
+		return nil, errors.Join(ErrAzureManagedIdentityFailed, errors.New("response contains no access token"))
+	}
+
+	now := g.client.now()
+	token := &credentialToken{value: resp.AccessToken, clock: g.client.now}
+	if expiresOn, err := strconv.ParseInt(resp.ExpiresOn.String(), 10, 64); err == nil {
+		token.validUntil = validUntil(now, time.Unix(expiresOn, 0), 0, 0)
+	} else if expiresIn, err := strconv.Atoi(resp.ExpiresIn.String()); err == nil {
+		token.validUntil = validUntil(now, now.Add(time.Duration(expiresIn)*time.Second), 0, 0)
+	}
+	return token, nil
+}
+
+// StoreToken returns the persisted form of the token.
+func (g *azureManagedIdentityGetter) StoreToken(token Token) *StoredToken {
+	return storeCredentialToken(token)
+}
+
+// RestoreToken returns the token of the persisted form.
+func (g *azureManagedIdentityGetter) RestoreToken(stored *StoredToken) Token {
+	return restoreCredentialToken(stored, g.client.now)
+}
@@ -0,0 +1,556 @@
+package vrest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheMaxBodySize = 10 << 20 // 10 MiB
+
+	// heuristicFreshnessDivisor is used for responses with Last-Modified,
+	// but without explicit freshness: 10% of the time since the last
+	// modification, as suggested by RFC 9111.
+	heuristicFreshnessDivisor = 10
+)
+
+// CacheStatus tells if a response was served from the HTTP cache.
+type CacheStatus string
+
+const (
+	// CacheHit means the response was served from the cache
+	// without contacting the server.
+	CacheHit CacheStatus = "hit"
+
+	// CacheRevalidated means the server confirmed with 304 Not Modified
+	// that the cached response is still valid.
+	CacheRevalidated CacheStatus = "revalidated"
+
+	// CacheMiss means the response was received from the server.
+	CacheMiss CacheStatus = "miss"
+)
+
+// heuristicallyCacheable are the status codes that can be cached,
+// see RFC 9110, section 15.1.
+var heuristicallyCacheable = []int{
+	http.StatusOK,
+	http.StatusNonAuthoritativeInfo,
+	http.StatusNoContent,
+	http.StatusMultipleChoices,
+	http.StatusMovedPermanently,
+	http.StatusNotFound,
+	http.StatusMethodNotAllowed,
+	http.StatusGone,
+	http.StatusRequestURITooLong,
+	http.StatusNotImplemented,
+	http.StatusPermanentRedirect,
+}
+
+// CacheStorage stores cached responses. Implementations must be safe for concurrent use.
+type CacheStorage interface {
+	// Get returns the cached response for the key.
+	// It returns nil and no error, if nothing is cached.
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+
+	// Set stores the response for the key.
+	Set(ctx context.Context, key string, resp *CachedResponse) error
+
+	// Delete removes the response for the key.
+	Delete(ctx context.Context, key string) error
+}
+
+// CachedResponse is a response stored in the HTTP cache.
+type CachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+
+	// VaryHeader holds the request headers selected by the Vary header of the response.
+	VaryHeader http.Header `json:"vary_header,omitempty"`
+
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+}
+
+// HTTPCache is a private HTTP cache (RFC 9111) for GET requests.
+// It honors Cache-Control, Expires and Vary and revalidates stale
+// responses with If-None-Match and If-Modified-Since. As a private
+// cache, it also stores responses marked as private.
+// Stale responses are only served if the request allows it with
+// max-stale and the response is not marked as must-revalidate.
+// Responses of authorized requests are only used for requests with the
+// same Authorization header, so clients with different credentials can
+// share a storage. Tokens of a token getter are part of the header, so a
+// new token starts with an empty cache.
+// Successful requests with unsafe methods invalidate the cached
+// response of their URL.
+type HTTPCache struct {
+	Storage CacheStorage
+
+	// MaxBodySize is the maximum size of a cached response body.
+	// Larger responses are not cached. It defaults to 10 MiB.
+	MaxBodySize int64
+}
+
+// NewHTTPCache creates an HTTP cache that stores responses in the given storage.
+func NewHTTPCache(storage CacheStorage) *HTTPCache {
+	return &HTTPCache{
+		Storage:     storage,
+		MaxBodySize: defaultCacheMaxBodySize,
+	}
+}
+
+// SetHTTPCache adds the HTTP cache as HTTP middleware to the client.
+// Response.CacheStatus tells if a response was served from the cache.
+func (c *Client) SetHTTPCache(cache *HTTPCache) *Client {
+	return c.UseHTTP(cache.Middleware)
+}
+
+// Middleware is the HTTPMiddleware of the cache.
+func (hc *HTTPCache) Middleware(req *Request, next HTTPDoer) (*http.Response, error) {
+	raw := req.Raw
+	if raw.Method != http.MethodGet {
+		resp, err := next(req)
+		if err == nil && isUnsafeMethod(raw.Method) && resp.StatusCode < http.StatusBadRequest {
+			hc.delete(req, cacheKey(req))
+		}
+		return resp, err
+	}
+
+	requestCC := parseCacheControl(raw.Header)
+	if requestCC.has("no-store") {
+		return next(req)
+	}
+
+	key := cacheKey(req)
+	entry := hc.get(req, key)
+	now := req.Client.now()
+	if entry != nil && !requestCC.has("no-cache") && entry.isFresh(now, requestCC) {
+		req.Response.CacheStatus = CacheHit
+		return entry.response(raw, now), nil
+	}
+
+	// the validators are only added to this attempt, the headers
+	// of the caller decide whether the request is conditional
+	conditional := entry != nil && entry.hasValidators() && !isConditional(raw.Header)
+	if conditional {
+		req.Raw = raw.Clone(raw.Context())
+		entry.setValidators(req.Raw.Header)
+	}
+	resp, err := next(req)
+	req.Raw = raw
+	if err != nil {
+		return nil, err
+	}
+
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		entry.revalidated(resp.Header, now, req.Client.now())
+		hc.set(req, key, entry)
+		req.Response.CacheStatus = CacheRevalidated
+		return entry.response(raw, req.Client.now()), nil
+	}
+
+	req.Response.CacheStatus = CacheMiss
+	return hc.store(req, key, resp, now)
+}
+
+// store caches the response, if it is cacheable.
+// The response body is read and replaced.
+func (hc *HTTPCache) store(req *Request, key string, resp *http.Response, requestTime time.Time) (*http.Response, error) {
+	responseCC := parseCacheControl(resp.Header)
+	if !slices.Contains(heuristicallyCacheable, resp.StatusCode) ||
+		responseCC.has("no-store") ||
+		resp.Header.Get("Vary") == "*" {
+		return resp, nil
+	}
+
+	entry := &CachedResponse{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		RequestTime:  requestTime,
+		ResponseTime: req.Client.now(),
+	}
+	if entry.freshnessLifetime() <= 0 && !entry.hasValidators() {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, hc.MaxBodySize+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > hc.MaxBodySize {
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry.Body = body
+	entry.VaryHeader = varyHeader(resp.Header, req.Raw.Header)
+	hc.set(req, key, entry)
+	return resp, nil
+}
+
+func (hc *HTTPCache) get(req *Request, key string) *CachedResponse {
+	entry, err := hc.Storage.Get(req.Context, key)
+	if err != nil {
+		req.Client.logger.LogAttrs(req.Context, slog.LevelWarn,
+			"failed to get response from cache",
+			slog.String("error", err.Error()))
+		return nil
+	}
+	if entry == nil || !entry.matchesVary(req.Raw.Header) {
+		return nil
+	}
+	return entry
+}
+
+func (hc *HTTPCache) set(req *Request, key string, entry *CachedResponse) {
+	if err := hc.Storage.Set(req.Context, key, entry); err != nil {
+		req.Client.logger.LogAttrs(req.Context, slog.LevelWarn,
+			"failed to store response in cache",
+			slog.String("error", err.Error()))
+	}
+}
+
+func (hc *HTTPCache) delete(req *Request, key string) {
+	if err := hc.Storage.Delete(req.Context, key); err != nil {
+		req.Client.logger.LogAttrs(req.Context, slog.LevelWarn,
+			"failed to delete response from cache",
+			slog.String("error", err.Error()))
+	}
+}
+
+// cacheKey returns the key of the GET response for the request URL.
+// Authorized responses are keyed by a hash of the Authorization header,
+// which includes the token of the token getter, so they are not shared
+// between credentials.
+func cacheKey(req *Request) string {
+	key := http.MethodGet + " " + req.Raw.URL.String()
+	if auth := req.Raw.Header.Get("Authorization"); auth != "" {
+		hash := sha256.Sum256([]byte(auth))
+		return key + " auth " + hex.EncodeToString(hash[:])
+	}
+	return key
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	default:
+		return true
+	}
+}
+
+// isFresh reports whether the response can be served without revalidation.
+// The request directives max-age, min-fresh and max-stale are honored.
+func (e *CachedResponse) isFresh(now time.Time, requestCC cacheControl) bool {
+	responseCC := parseCacheControl(e.Header)
+	if responseCC.has("no-cache") {
+		return false
+	}
+
+	age := e.age(now)
+	if maxAge, ok := requestCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := requestCC.seconds("min-fresh"); ok {
+		age += minFresh
+	}
+
+	lifetime := e.freshnessLifetime()
+	if lifetime > age {
+		return true
+	}
+
+	// stale responses must not be served, if the response requires revalidation
+	if !requestCC.has("max-stale") || responseCC.has("must-revalidate") {
+		return false
+	}
+	maxStale, ok := requestCC.seconds("max-stale")
+	// max-stale without value accepts any staleness
+	return !ok || age-lifetime < maxStale
+}
+
+// freshnessLifetime returns the freshness lifetime, see RFC 9111, section 4.2.1.
+func (e *CachedResponse) freshnessLifetime() time.Duration {
+	if maxAge, ok := parseCacheControl(e.Header).seconds("max-age"); ok {
+		return maxAge
+	}
+
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			// invalid dates represent a time in the past
+			return 0
+		}
+		return t.Sub(e.date())
+	}
+
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		return e.date().Sub(lastModified) / heuristicFreshnessDivisor
+	}
+	return 0
+}
+
+// age returns the current age of the response, see RFC 9111, section 4.2.3.
+func (e *CachedResponse) age(now time.Time) time.Duration {
+	apparentAge := max(e.ResponseTime.Sub(e.date()), 0)
+	if ageValue, err := strconv.Atoi(e.Header.Get("Age")); err == nil {
+		apparentAge = max(apparentAge, time.Duration(ageValue)*time.Second)
+	}
+	return apparentAge + now.Sub(e.ResponseTime)
+}
+
+// date returns the Date header or the response time, if it is missing.
+func (e *CachedResponse) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+func (e *CachedResponse) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// setValidators adds the conditional request headers for revalidation.
+func (e *CachedResponse) setValidators(header http.Header) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// isConditional reports whether the request has own conditional headers.
+func isConditional(header http.Header) bool {
+	return header.Get("If-None-Match") != "" || header.Get("If-Modified-Since") != ""
+}
+
+// revalidated updates the stored headers with the headers of a 304 response.
+func (e *CachedResponse) revalidated(header http.Header, requestTime, responseTime time.Time) {
+	for key, values := range header {
+		if key != "Content-Length" {
+			e.Header[key] = slices.Clone(values)
+		}
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+// matchesVary reports whether the request headers selected by Vary
+// match the request headers of the cached response.
+func (e *CachedResponse) matchesVary(header http.Header) bool {
+	for _, name := range varyNames(e.Header) {
+		if !slices.Equal(e.VaryHeader.Values(name), header.Values(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// response returns a new HTTP response with the cached body.
+func (e *CachedResponse) response(raw *http.Request, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(e.age(now).Seconds())))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       raw,
+	}
+}
+
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// varyHeader returns the request headers selected by the Vary header of the response.
+func varyHeader(responseHeader, requestHeader http.Header) http.Header {
+	names := varyNames(responseHeader)
+	if len(names) == 0 {
+		return nil
+	}
+
+	header := make(http.Header, len(names))
+	for _, name := range names {
+		if values := requestHeader.Values(name); len(values) > 0 {
+			header[http.CanonicalHeaderKey(name)] = slices.Clone(values)
+		}
+	}
+	return header
+}
+
+// cacheControl holds the directives of Cache-Control headers.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(cc[directive])
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(max(seconds, 0)) * time.Second, true
+}
+
+// readCloser combines a reader with the closer of another reader.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// MemoryCacheStorage is a CacheStorage that keeps responses in memory.
+type MemoryCacheStorage struct {
+	mu        sync.Mutex
+	responses map[string]*CachedResponse
+}
+
+// NewMemoryCacheStorage creates an empty in-memory cache storage.
+func NewMemoryCacheStorage() *MemoryCacheStorage {
+	return &MemoryCacheStorage{responses: make(map[string]*CachedResponse)}
+}
+
+// Get returns a copy of the cached response for the key.
+func (s *MemoryCacheStorage) Get(_ context.Context, key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp, ok := s.responses[key]
+	if !ok {
+		return nil, nil
+	}
+	return resp.clone(), nil
+}
+
+// Set stores a copy of the response for the key.
+func (s *MemoryCacheStorage) Set(_ context.Context, key string, resp *CachedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[key] = resp.clone()
+	return nil
+}
+
+// Delete removes the response for the key.
+func (s *MemoryCacheStorage) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.responses, key)
+	return nil
+}
+
+func (e *CachedResponse) clone() *CachedResponse {
+	c := *e
+	c.Header = e.Header.Clone()
+	c.VaryHeader = e.VaryHeader.Clone()
+	return &c
+}
+
+// DiskCacheStorage is a CacheStorage that keeps one file per key in a directory.
+// Files are written atomically and are only readable by the current user.
+type DiskCacheStorage struct {
+	Dir string
+}
+
+// NewDiskCacheStorage creates a cache storage for the given directory.
+// The directory is created when the first response is stored.
+func NewDiskCacheStorage(dir string) *DiskCacheStorage {
+	return &DiskCacheStorage{Dir: dir}
+}
+
+// Get returns the cached response for the key.
+func (s *DiskCacheStorage) Get(_ context.Context, key string) (*CachedResponse, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp CachedResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("invalid cache file: %w", err)
+	}
+	return &resp, nil
+}
+
+// Set stores the response for the key.
+func (s *DiskCacheStorage) Set(_ context.Context, key string, resp *CachedResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(key), data)
+}
+
+// Delete removes the response for the key.
+func (s *DiskCacheStorage) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path returns the file path for the key. The key is hashed,
+// so it can contain any characters.
+func (s *DiskCacheStorage) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(hash[:])+".json")
+}
@@ -0,0 +1,249 @@
+package vrest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPCache(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+		}
+	}))
+	defer ts.Close()
+
+	storages := map[string]CacheStorage{
+		"memory": NewMemoryCacheStorage(),
+		"disk":   NewDiskCacheStorage(t.TempDir()),
+	}
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			c := NewWithClient(ts.Client()).
+				SetBaseURL(ts.URL).
+				SetClock(func() time.Time { return now }).
+				SetHTTPCache(NewHTTPCache(storage))
+
+			get := func(path, language string, wantStatus CacheStatus, wantRequests int32) {
+				t.Helper()
+				requests.Store(0)
+
+				var body struct {
+					Path string `json:"path"`
+				}
+				req := c.NewRequest().
+					SetHeaderIf(language != "", "Accept-Language", language).
+					SetResponseBody(&body)
+				if err := req.DoGet(path); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if body.Path != path {
+					t.Fatalf("unexpected body: %q", req.Response.BodyBytes)
+				}
+				if req.Response.CacheStatus != wantStatus {
+					t.Fatalf("unexpected cache status: %q, want %q", req.Response.CacheStatus, wantStatus)
+				}
+				if requests.Load() != wantRequests {
+					t.Fatalf("unexpected number of server requests: %d", requests.Load())
+				}
+			}
+
+			get("/fresh", "", CacheMiss, 1)
+			get("/fresh", "", CacheHit, 0)
+
+			get("/etag", "", CacheMiss, 1)
+			get("/etag", "", CacheRevalidated, 1)
+
+			get("/vary", "de", CacheMiss, 1)
+			get("/vary", "de", CacheHit, 0)
+			get("/vary", "en", CacheMiss, 1)
+
+			get("/no-store", "", CacheMiss, 1)
+			get("/no-store", "", CacheMiss, 1)
+
+			// unsafe methods invalidate the cached response
+			get("/vary", "en", CacheHit, 0)
+			if err := c.NewRequest().DoPost("/vary"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			get("/vary", "en", CacheMiss, 1)
+
+			// stale responses are requested again
+			now = now.Add(time.Minute)
+			get("/fresh", "", CacheMiss, 1)
+		})
+	}
+}
+
+func TestHTTPCache_revalidateOnRetry(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) == 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("v1"))
+	}))
+	defer ts.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetRetryPolicy(policy).
+		SetHTTPCache(NewHTTPCache(NewMemoryCacheStorage()))
+
+	if err := c.NewRequest().DoGet("/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the first revalidation fails with 503, the retry is revalidated again
+	req := c.NewRequest()
+	if err := req.DoGet("/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Response.CacheStatus != CacheRevalidated || string(req.Response.BodyBytes) != "v1" {
+		t.Fatalf("unexpected response %q: %q", req.Response.CacheStatus, req.Response.BodyBytes)
+	}
+	if req.Header.Get("If-None-Match") != "" {
+		t.Fatal("validators must not be added to the request headers")
+	}
+}
+
+func TestHTTPCache_authorization(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetHTTPCache(NewHTTPCache(NewMemoryCacheStorage()))
+
+	get := func(auth string, wantStatus CacheStatus) {
+		t.Helper()
+		req := c.NewRequest().SetAuthorization(auth)
+		if err := req.DoGet("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.Response.CacheStatus != wantStatus || string(req.Response.BodyBytes) != auth {
+			t.Fatalf("unexpected response %q: %q", req.Response.CacheStatus, req.Response.BodyBytes)
+		}
+	}
+
+	get("Bearer alice", CacheMiss)
+	get("Bearer alice", CacheHit)
+	get("Bearer bob", CacheMiss)
+	if requests.Load() != 2 {
+		t.Fatalf("unexpected number of server requests: %d", requests.Load())
+	}
+}
+
+// staticTokenGetter always returns the same token.
+type staticTokenGetter string
+
+func (g staticTokenGetter) GetToken(_ context.Context, _ Token) (Token, error) {
+	return testToken(g), nil
+}
+
+func TestHTTPCache_tokenGetters(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	// both clients share one storage, but must not read each other's responses
+	storage := NewMemoryCacheStorage()
+	for _, token := range []string{"alice", "bob", "alice"} {
+		req := NewWithClient(ts.Client()).
+			SetBaseURL(ts.URL).
+			SetTokenGetter(staticTokenGetter(token)).
+			SetHTTPCache(NewHTTPCache(storage)).
+			NewRequest()
+		if err := req.DoGet("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := string(req.Response.BodyBytes); got != "Bearer "+token {
+			t.Fatalf("unexpected response for %s: %q", token, got)
+		}
+	}
+}
+
+func TestHTTPCache_maxStale(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/must-revalidate" {
+			w.Header().Set("Cache-Control", "max-age=60, must-revalidate")
+		}
+	}))
+	defer ts.Close()
+
+	now := time.Now()
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetClock(func() time.Time { return now }).
+		SetHTTPCache(NewHTTPCache(NewMemoryCacheStorage()))
+
+	for _, path := range []string{"/stale", "/must-revalidate"} {
+		if err := c.NewRequest().DoGet(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	now = now.Add(90 * time.Second)
+
+	tests := []struct {
+		path         string
+		cacheControl string
+		wantStatus   CacheStatus
+	}{
+		{"/stale", "max-stale=10", CacheMiss},
+		{"/stale", "max-stale=60", CacheHit},
+		{"/stale", "max-stale", CacheHit},
+		{"/must-revalidate", "max-stale", CacheMiss},
+	}
+	for _, tt := range tests {
+		req := c.NewRequest().SetHeader("Cache-Control", tt.cacheControl)
+		if err := req.DoGet(tt.path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.Response.CacheStatus != tt.wantStatus {
+			t.Fatalf("%s with %s: unexpected cache status %q", tt.path, tt.cacheControl, req.Response.CacheStatus)
+		}
+	}
+}
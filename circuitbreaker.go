@@ -0,0 +1,273 @@
+package vrest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitOpenTimeout      = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets all requests pass.
+	CircuitClosed CircuitState = "closed"
+
+	// CircuitOpen rejects all requests with ErrCircuitOpen.
+	CircuitOpen CircuitState = "open"
+
+	// CircuitHalfOpen lets a limited number of trial requests pass.
+	// If they succeed, the circuit is closed, otherwise it is opened again.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerConfig configures a circuit breaker.
+type CircuitBreakerConfig struct {
+	// Key selects the circuit of a request, for example KeyByRoute.
+	// It defaults to KeyByHost.
+	Key func(req *Request) string
+
+	// FailureThreshold is the number of consecutive failures
+	// that open the circuit. It defaults to 5.
+	FailureThreshold int
+
+	// OpenTimeout is the time the circuit stays open,
+	// before it becomes half-open. It defaults to 30 seconds.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of trial requests in the half-open
+	// state. All of them must succeed to close the circuit. It defaults to 1.
+	HalfOpenRequests int
+
+	// IsFailure replaces the default failure decision, if set. By default,
+	// errors of the HTTP client and responses for which Overridable.IsSuccess
+	// returns false are failures. Canceled request contexts are ignored.
+	IsFailure func(req *Request, err error) bool
+
+	// OnStateChange is called when the state of a circuit changes.
+	// The changes are delivered one at a time and in order.
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+// circuitResult is the result of a request that passed a circuit.
+type circuitResult int
+
+const (
+	circuitSuccess circuitResult = iota
+	circuitFailure
+	// circuitIgnored is the result of canceled requests.
+	circuitIgnored
+)
+
+// circuitBreaker holds the circuits of a circuit breaker config.
+type circuitBreaker struct {
+	config   CircuitBreakerConfig
+	mu       sync.Mutex
+	circuits map[string]*circuit
+
+	// changes are the state changes that are not yet passed to
+	// OnStateChange. notifying is set while a goroutine passes them.
+	// Both are guarded by mu.
+	changes   []circuitChange
+	notifying bool
+}
+
+// circuitChange is a state change of a circuit.
+type circuitChange struct {
+	key      string
+	from, to CircuitState
+}
+
+// circuit is the state of a single circuit. The generation is increased
+// with every state change, so results of requests that were started in
+// a previous state are ignored.
+type circuit struct {
+	state      CircuitState
+	generation uint64
+	failures   int
+	trials     int
+	successes  int
+	openedAt   time.Time
+}
+
+// SetCircuitBreaker adds a circuit breaker as HTTP middleware to the client.
+// While a circuit is open, requests fail immediately with ErrCircuitOpen
+// and are not retried. Request.CircuitState tells the state of the circuit
+// when the attempt was made.
+func (c *Client) SetCircuitBreaker(cfg CircuitBreakerConfig) *Client {
+	if cfg.Key == nil {
+		cfg.Key = KeyByHost
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultCircuitFailureThreshold
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultCircuitOpenTimeout
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+
+	breaker := &circuitBreaker{
+		config:   cfg,
+		circuits: make(map[string]*circuit),
+	}
+	return c.UseHTTP(breaker.middleware)
+}
+
+func (b *circuitBreaker) middleware(req *Request, next HTTPDoer) (*http.Response, error) {
+	key := b.config.Key(req)
+	generation, state, ok := b.allow(key, req.Client.now())
+	req.CircuitState = state
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+	}
+
+	resp, err := next(req)
+
+	// IsSuccess needs the response
+	req.Response.Raw = resp
+	b.record(key, generation, b.result(req, err), req.Client.now())
+	return resp, err
+}
+
+// allow reports whether a request may pass the circuit.
+func (b *circuitBreaker) allow(key string, now time.Time) (uint64, CircuitState, bool) {
+	b.mu.Lock()
+	cb, ok := b.circuits[key]
+	if !ok {
+		cb = &circuit{state: CircuitClosed}
+		b.circuits[key] = cb
+	}
+
+	from := cb.state
+	if cb.state == CircuitOpen && now.Sub(cb.openedAt) >= b.config.OpenTimeout {
+		cb.setState(CircuitHalfOpen, now)
+	}
+
+	allowed := true
+	switch cb.state {
+	case CircuitOpen:
+		allowed = false
+	case CircuitHalfOpen:
+		allowed = cb.trials < b.config.HalfOpenRequests
+		if allowed {
+			cb.trials++
+		}
+	}
+	generation, to := cb.generation, cb.state
+	b.changed(key, from, to)
+	b.mu.Unlock()
+
+	b.notify()
+	return generation, to, allowed
+}
+
+// record records the result of a request that passed the circuit.
+func (b *circuitBreaker) record(key string, generation uint64, result circuitResult, now time.Time) {
+	b.mu.Lock()
+	cb := b.circuits[key]
+	from := cb.state
+	if generation == cb.generation {
+		switch {
+		case result == circuitIgnored:
+			if cb.state == CircuitHalfOpen {
+				// another request can try
+				cb.trials--
+			}
+		case result == circuitFailure && cb.state == CircuitHalfOpen:
+			cb.setState(CircuitOpen, now)
+		case result == circuitFailure:
+			cb.failures++
+			if cb.failures >= b.config.FailureThreshold {
+				cb.setState(CircuitOpen, now)
+			}
+		case cb.state == CircuitHalfOpen:
+			cb.successes++
+			if cb.successes >= b.config.HalfOpenRequests {
+				cb.setState(CircuitClosed, now)
+			}
+		default:
+			cb.failures = 0
+		}
+	}
+	b.changed(key, from, cb.state)
+	b.mu.Unlock()
+
+	b.notify()
+}
+
+func (b *circuitBreaker) result(req *Request, err error) circuitResult {
+	var failure bool
+	switch {
+	case b.config.IsFailure != nil:
+		failure = b.config.IsFailure(req, err)
+	case errors.Is(err, context.Canceled):
+		return circuitIgnored
+	case err != nil:
+		failure = true
+	default:
+		failure = !req.Overridable.IsSuccess(req)
+	}
+
+	if failure {
+		return circuitFailure
+	}
+	return circuitSuccess
+}
+
+// changed queues a state change for OnStateChange.
+// It must be called with b.mu locked.
+func (b *circuitBreaker) changed(key string, from, to CircuitState) {
+	if from != to && b.config.OnStateChange != nil {
+		b.changes = append(b.changes, circuitChange{key: key, from: from, to: to})
+	}
+}
+
+// notify passes the queued state changes to OnStateChange. Only one
+// goroutine passes them at a time, so they are delivered in order.
+// OnStateChange is called without lock, so it can use the client.
+func (b *circuitBreaker) notify() {
+	if b.config.OnStateChange == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.notifying {
+		return
+	}
+
+	b.notifying = true
+	for len(b.changes) > 0 {
+		change := b.changes[0]
+		b.changes = b.changes[1:]
+
+		b.mu.Unlock()
+		b.config.OnStateChange(change.key, change.from, change.to)
+		b.mu.Lock()
+	}
+	b.notifying = false
+}
+
+// setState changes the state and resets the counters.
+func (cb *circuit) setState(state CircuitState, now time.Time) {
+	cb.state = state
+	cb.generation++
+	cb.failures = 0
+	cb.trials = 0
+	cb.successes = 0
+	if state == CircuitOpen {
+		cb.openedAt = now
+	}
+}
@@ -0,0 +1,109 @@
+package vrest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_SetCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	now := time.Now()
+	var transitions []CircuitState
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetClock(func() time.Time { return now }).
+		SetCircuitBreaker(CircuitBreakerConfig{
+			FailureThreshold: 2,
+			OpenTimeout:      time.Minute,
+			OnStateChange: func(_ string, _, to CircuitState) {
+				transitions = append(transitions, to)
+			},
+		})
+
+	do := func(wantState CircuitState, wantErr bool, wantRequests int32) {
+		t.Helper()
+		requests.Store(0)
+
+		req := c.NewRequest()
+		err := req.DoGet("/")
+		if (err != nil) != wantErr {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if errors.Is(err, ErrCircuitOpen) != (wantState == CircuitOpen) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.CircuitState != wantState {
+			t.Fatalf("unexpected circuit state: %q, want %q", req.CircuitState, wantState)
+		}
+		if requests.Load() != wantRequests {
+			t.Fatalf("unexpected number of server requests: %d", requests.Load())
+		}
+	}
+
+	healthy.Store(true)
+	do(CircuitClosed, false, 1)
+
+	healthy.Store(false)
+	do(CircuitClosed, true, 1)
+	do(CircuitClosed, true, 1)
+	do(CircuitOpen, true, 0)
+
+	// a failed trial request opens the circuit again
+	now = now.Add(time.Minute)
+	do(CircuitHalfOpen, true, 1)
+	do(CircuitOpen, true, 0)
+
+	// a successful trial request closes the circuit
+	now = now.Add(time.Minute)
+	healthy.Store(true)
+	do(CircuitHalfOpen, false, 1)
+	do(CircuitClosed, false, 1)
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if !slices.Equal(transitions, want) {
+		t.Fatalf("unexpected state changes: %v, want %v", transitions, want)
+	}
+}
+
+func TestClient_SetCircuitBreaker_retry(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 5
+	policy.InitialBackoff = time.Millisecond
+	policy.ShouldRetry = func(*Request, error) bool { return true }
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetRetryPolicy(policy).
+		SetCircuitBreaker(CircuitBreakerConfig{Key: KeyByRoute, FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	// even a custom retry decision doesn't retry the open circuit
+	req := c.NewRequest()
+	if err := req.DoGet("/"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests.Load() != 2 || req.Attempt != 3 {
+		t.Fatalf("unexpected attempts: requests %d, req.Attempt %d", requests.Load(), req.Attempt)
+	}
+}
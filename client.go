@@ -8,7 +8,6 @@ import (
 	"log/slog"
 	"net/http"
 	"reflect"
-	"sync"
 	"time"
 )
 
@@ -20,22 +19,47 @@ type (
 type Client struct {
 	BaseURL string
 
-	ResponseBodyLimit int64
-	TraceBodies       bool
+	ResponseBodyLimit       int64
+	ResponseBodyLimitPolicy BodyLimitPolicy
+	TraceBodies             bool
 
 	ContentType   string
 	Authorization string
 	TokenGetter   TokenGetter
+	ReauthMode    ReauthMode
+
+	// TokenTimeout limits a token refresh. The refresh is shared by all
+	// requests waiting for the token, so it does not use the request
+	// context's deadline. It defaults to 30 seconds and to 10 minutes
+	// for interactive OAuth grants.
+	TokenTimeout time.Duration
+
+	// TokenStore persists the tokens of the TokenGetter, see SetTokenStore.
+	TokenStore    TokenStore
+	TokenStoreKey string
 
 	ErrorType reflect.Type
 
+	// Codecs maps media types to the codecs used to marshal request
+	// bodies and unmarshal response bodies. See RegisterCodec.
+	Codecs map[string]Codec
+
+	RetryPolicy *RetryPolicy
+
+	// Clock returns the current time. It is used for token expiry
+	// and defaults to time.Now. Tests can set a fake clock.
+	Clock func() time.Time
+
+	Middlewares     []Middleware
+	HTTPMiddlewares []HTTPMiddleware
+
 	Overridable Overridables
 
 	httpClient *http.Client
 	traceMaker TraceMaker
 	logger     *slog.Logger
-	token      atomicToken
-	tokenMutex sync.Mutex
+	tokens     tokenCache
+	refresher  *tokenRefresher
 }
 
 type Overridables struct {
@@ -81,6 +105,7 @@ func NewWithClient(httpClient *http.Client) *Client {
 		httpClient:  httpClient,
 		logger:      slog.Default(),
 		TraceBodies: true,
+		Codecs:      DefaultCodecs(),
 
 		Overridable: Overridables{
 			Do:            Do,
@@ -123,7 +148,17 @@ func (c *Client) SetErrorBodyType(value error) *Client {
 	return c
 }
 
+// SetRetryPolicy sets the retry policy for all requests of the client.
+// Use DefaultRetryPolicy() as a starting point.
+// By default, requests are not retried.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) *Client {
+	c.RetryPolicy = &policy
+	return c
+}
+
 // SetBaseURL sets the base URL for the client.
+// Request paths are appended to it, but absolute http and https URLs
+// are requested as they are.
 func (c *Client) SetBaseURL(baseURL string) *Client {
 	c.BaseURL = baseURL
 	return c
@@ -167,6 +202,26 @@ func (c *Client) SetOAuth(cfg OAuthConfig) *Client {
 	})
 }
 
+// SetClock sets the clock used for token expiry.
+func (c *Client) SetClock(clock func() time.Time) *Client {
+	c.Clock = clock
+	return c
+}
+
+// now returns the current time of the client's clock.
+func (c *Client) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock()
+}
+
+// SetTokenTimeout sets the timeout of a token refresh.
+func (c *Client) SetTokenTimeout(timeout time.Duration) *Client {
+	c.TokenTimeout = timeout
+	return c
+}
+
 // SetTokenGetter sets a custom token getter for the client.
 // See the readme and examples for how to implement a custom token getter.
 func (c *Client) SetTokenGetter(tokenGetter TokenGetter) *Client {
@@ -181,7 +236,9 @@ func (c *Client) SetAuthorization(auth string) *Client {
 }
 
 // SetResponseBodyLimit sets the response body limit for the client.
-// If the response body is larger than the limit, it will be truncated.
+// If the response body is larger than the limit, the request fails with
+// ErrResponseBodyTooLarge, unless a different policy is set with
+// SetResponseBodyLimitPolicy.
 // If the limit is 0, the response body will not be limited which can be
 // used by an attacker to perform a DoS attack.
 func (c *Client) SetResponseBodyLimit(limit int64) *Client {
@@ -189,6 +246,13 @@ func (c *Client) SetResponseBodyLimit(limit int64) *Client {
 	return c
 }
 
+// SetResponseBodyLimitPolicy sets what happens if a response body
+// exceeds the response body limit. The default is BodyLimitError.
+func (c *Client) SetResponseBodyLimitPolicy(policy BodyLimitPolicy) *Client {
+	c.ResponseBodyLimitPolicy = policy
+	return c
+}
+
 // JSONMarshal marshals the given value into JSON
 // without escaping HTML characters.
 func JSONMarshal(req *Request, v interface{}) ([]byte, error) {
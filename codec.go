@@ -0,0 +1,163 @@
+package vrest
+
+import (
+	"encoding"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// Codec marshals request bodies and unmarshals response bodies of a media type.
+type Codec interface {
+	// Marshal encodes v into the body bytes.
+	Marshal(req *Request, v any) ([]byte, error)
+
+	// Unmarshal decodes the body bytes into v, which is always a pointer.
+	Unmarshal(req *Request, data []byte, v any) error
+}
+
+// DefaultCodecs returns the codecs that are registered for a new client:
+// JSON, XML, form-urlencoded and plain text.
+// The JSON and XML codecs use the JSON and XML functions of Overridables.
+func DefaultCodecs() map[string]Codec {
+	return map[string]Codec{
+		"application/json":                  JSONCodec{},
+		"text/json":                         JSONCodec{},
+		"+json":                             JSONCodec{},
+		"application/xml":                   XMLCodec{},
+		"text/xml":                          XMLCodec{},
+		"+xml":                              XMLCodec{},
+		"application/x-www-form-urlencoded": FormCodec{},
+		"text/plain":                        TextCodec{},
+	}
+}
+
+// RegisterCodec registers the codec for the given media type, for example
+// "text/csv". Structured syntax suffixes like "+json" can be registered
+// as well, they are used if there is no codec for the exact media type.
+// An existing codec for the media type is replaced.
+func (c *Client) RegisterCodec(mediaType string, codec Codec) *Client {
+	if c.Codecs == nil {
+		c.Codecs = make(map[string]Codec)
+	}
+	c.Codecs[strings.ToLower(mediaType)] = codec
+	return c
+}
+
+// CodecFor returns the codec for the given content type. Parameters like
+// charset are ignored. If there is no codec for the exact media type,
+// the codec for its structured syntax suffix (e.g. "+json") is returned.
+func (c *Client) CodecFor(contentType string) (Codec, bool) {
+	mediaType := parseMediaType(contentType)
+	if mediaType == "" {
+		return nil, false
+	}
+
+	if codec, ok := c.Codecs[mediaType]; ok {
+		return codec, true
+	}
+
+	if i := strings.LastIndex(mediaType, "+"); i > 0 {
+		if codec, ok := c.Codecs[mediaType[i:]]; ok {
+			return codec, true
+		}
+	}
+
+	return nil, false
+}
+
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// JSONCodec is the codec for JSON bodies.
+// It uses Overridables.JSONMarshal and Overridables.JSONUnmarshal.
+type JSONCodec struct{}
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(req *Request, v any) ([]byte, error) {
+	return req.Overridable.JSONMarshal(req, v)
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(req *Request, data []byte, v any) error {
+	return req.Overridable.JSONUnmarshal(req, data, v)
+}
+
+// XMLCodec is the codec for XML bodies.
+// It uses Overridables.XMLMarshal and Overridables.XMLUnmarshal.
+type XMLCodec struct{}
+
+// Marshal encodes v as XML.
+func (XMLCodec) Marshal(req *Request, v any) ([]byte, error) {
+	return req.Overridable.XMLMarshal(req, v)
+}
+
+// Unmarshal decodes XML data into v.
+func (XMLCodec) Unmarshal(req *Request, data []byte, v any) error {
+	return req.Overridable.XMLUnmarshal(req, data, v)
+}
+
+// FormCodec is the codec for application/x-www-form-urlencoded bodies.
+// It marshals all values supported by EncodeForm and unmarshals into *url.Values.
+type FormCodec struct{}
+
+// Marshal encodes v as form.
+func (FormCodec) Marshal(_ *Request, v any) ([]byte, error) {
+	values, err := EncodeForm(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+// Unmarshal decodes form data into v.
+func (FormCodec) Unmarshal(_ *Request, data []byte, v any) error {
+	target, ok := v.(*url.Values)
+	if !ok {
+		return fmt.Errorf("%w: can't unmarshal form into %T", ErrResponseNotUnmarshaled, v)
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	*target = values
+	return nil
+}
+
+// TextCodec is the codec for text/plain bodies.
+// It marshals strings, fmt.Stringer and encoding.TextMarshaler values
+// and unmarshals into *string values only, because servers and sniffers
+// often fall back to text/plain for bodies of other formats.
+type TextCodec struct{}
+
+// Marshal encodes v as text.
+func (TextCodec) Marshal(_ *Request, v any) ([]byte, error) {
+	switch value := v.(type) {
+	case string:
+		return []byte(value), nil
+	case encoding.TextMarshaler:
+		return value.MarshalText()
+	case fmt.Stringer:
+		return []byte(value.String()), nil
+	default:
+		return nil, fmt.Errorf("can't marshal %T as text", v)
+	}
+}
+
+// Unmarshal decodes text data into v.
+func (TextCodec) Unmarshal(_ *Request, data []byte, v any) error {
+	switch target := v.(type) {
+	case *string:
+		*target = string(data)
+		return nil
+	default:
+		return fmt.Errorf("%w: can't unmarshal text into %T", ErrResponseNotUnmarshaled, v)
+	}
+}
@@ -0,0 +1,78 @@
+package vrest
+
+import (
+	"strings"
+	"testing"
+)
+
+type testLinesCodec struct{}
+
+func (testLinesCodec) Marshal(_ *Request, v any) ([]byte, error) {
+	return []byte(strings.Join(v.([]string), "\n")), nil // nolint:forcetypeassert
+}
+
+func (testLinesCodec) Unmarshal(_ *Request, data []byte, v any) error {
+	*v.(*[]string) = strings.Split(string(data), "\n") // nolint:forcetypeassert
+	return nil
+}
+
+func TestClient_CodecFor(t *testing.T) {
+	c := New().RegisterCodec("text/x-lines", testLinesCodec{})
+
+	tests := []struct {
+		contentType string
+		want        Codec
+	}{
+		{"application/json; charset=utf-8", JSONCodec{}},
+		{"application/problem+json", JSONCodec{}},
+		{"application/atom+xml", XMLCodec{}},
+		{"Text/X-Lines", testLinesCodec{}},
+		{"text/csv", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, ok := c.CodecFor(tt.contentType)
+		if ok != (tt.want != nil) || got != tt.want {
+			t.Errorf("CodecFor(%q) = %T, want %T", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestRequest_customCodec(t *testing.T) {
+	c := New().RegisterCodec("text/x-lines", testLinesCodec{})
+	mock := &MockHTTPResponse{BodyString: "c\nd", ContentType: "text/x-lines"}
+	c.Overridable.DoHTTPRequest = MockHTTPDoer(mock)
+
+	var lines []string
+	err := c.NewRequest().
+		SetContentType("text/x-lines").
+		SetBody([]string{"a", "b"}).
+		SetResponseBody(&lines).
+		DoPost("/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := string(mock.CapturedRequest.BodyBytes); got != "a\nb" {
+		t.Fatalf("unexpected request body %q", got)
+	}
+	if strings.Join(lines, ",") != "c,d" {
+		t.Fatalf("unexpected response body %v", lines)
+	}
+}
+
+func TestRequest_emptyTextBody(t *testing.T) {
+	c := New()
+	c.Overridable.DoHTTPRequest = MockHTTPDoer(&MockHTTPResponse{ContentType: "text/plain"})
+
+	var body struct{ Name string }
+	req := c.NewRequest().SetResponseBody(&body)
+	if err := req.DoGet("/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	didUnmarshal, err := req.unmarshalResponseBody(&body)
+	if didUnmarshal || err != nil {
+		t.Fatalf("unexpected result: %v, %v", didUnmarshal, err)
+	}
+}
@@ -0,0 +1,157 @@
+package vrest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var ErrExecCredentialFailed = errors.New("exec credential plugin failed")
+
+// credentialToken is a token of the built-in credential getters.
+// A zero validUntil means that the token does not expire.
+type credentialToken struct {
+	value      string
+	validUntil time.Time
+	clock      func() time.Time
+
+	// changed reports whether the source of the token has changed,
+	// for example because a token file was rotated.
+	changed func() bool
+}
+
+// Token returns the actual token.
+func (t *credentialToken) Token() string {
+	return t.value
+}
+
+// ExpiresAt returns the time when the token is no longer valid.
+// It is zero for tokens without expiry.
+func (t *credentialToken) ExpiresAt() time.Time {
+	return t.validUntil
+}
+
+// NeedsRefresh returns true if the token expired or its source has changed.
+func (t *credentialToken) NeedsRefresh() bool {
+	if t.value == "" {
+		return true
+	}
+	if t.changed != nil && t.changed() {
+		return true
+	}
+	return !t.validUntil.IsZero() && t.clock().After(t.validUntil)
+}
+
+// storeCredentialToken returns the persisted form of a credential token.
+func storeCredentialToken(token Token) *StoredToken {
+	credToken, ok := token.(*credentialToken)
+	if !ok {
+		return nil
+	}
+	return &StoredToken{AccessToken: credToken.value, ValidUntil: credToken.validUntil}
+}
+
+// restoreCredentialToken returns the credential token of the persisted form.
+func restoreCredentialToken(stored *StoredToken, clock func() time.Time) Token {
+	return &credentialToken{value: stored.AccessToken, validUntil: stored.ValidUntil, clock: clock}
+}
+
+// ExecCredentialConfig is the configuration of an exec credential plugin.
+// The command must print a JSON object to stdout, either with the fields
+// access_token and optionally expires_at (RFC 3339) or expires_in (seconds),
+// expires_at takes precedence, or in the format of Kubernetes client-go credential plugins:
+//
+//	{"status": {"token": "...", "expirationTimestamp": "2024-05-01T12:00:00Z"}}
+//
+// Tokens without expiry are used until they are rejected by the server.
+type ExecCredentialConfig struct {
+	Command string
+	Args    []string
+
+	// Env are additional environment variables in the form key=value.
+	// The command inherits the environment of the current process.
+	Env []string
+
+	// Dir is the working directory of the command.
+	Dir string
+}
+
+type execCredentialGetter struct {
+	config ExecCredentialConfig
+	client *Client
+}
+
+// execCredentialOutput is the JSON output of an exec credential plugin.
+type execCredentialOutput struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Status      *struct {
+		Token               string    `json:"token"`
+		ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+// SetExecCredential sets a token getter that runs an external command
+// to get tokens, see ExecCredentialConfig.
+func (c *Client) SetExecCredential(cfg ExecCredentialConfig) *Client {
+	return c.SetTokenGetter(&execCredentialGetter{
+		config: cfg,
+		client: c,
+	})
+}
+
+// GetToken runs the command and parses its output.
+func (g *execCredentialGetter) GetToken(ctx context.Context, _ Token) (Token, error) {
+	// nolint:gosec // the command is configured by the application
+	cmd := exec.CommandContext(ctx, g.config.Command, g.config.Args...)
+	cmd.Env = append(os.Environ(), g.config.Env...)
+	cmd.Dir = g.config.Dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: %s", ErrExecCredentialFailed, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output execCredentialOutput
+	if err = json.Unmarshal(stdout, &output); err != nil {
+		return nil, fmt.Errorf("%w: invalid output: %w", ErrExecCredentialFailed, err)
+	}
+
+	now := g.client.now()
+	token := &credentialToken{value: output.AccessToken, clock: g.client.now}
+	expiresAt := output.ExpiresAt
+	if expiresAt.IsZero() && output.ExpiresIn > 0 {
+		expiresAt = now.Add(time.Duration(output.ExpiresIn) * time.Second)
+	}
+	if output.Status != nil {
+		token.value = output.Status.Token
+		expiresAt = output.Status.ExpirationTimestamp
+	}
+
+	if token.value == "" {
+		return nil, fmt.Errorf("%w: output contains no token", ErrExecCredentialFailed)
+	}
+	if !expiresAt.IsZero() {
+		token.validUntil = validUntil(now, expiresAt, 0, 0)
+	}
+	return token, nil
+}
+
+// StoreToken returns the persisted form of the token.
+func (g *execCredentialGetter) StoreToken(token Token) *StoredToken {
+	return storeCredentialToken(token)
+}
+
+// RestoreToken returns the token of the persisted form.
+func (g *execCredentialGetter) RestoreToken(stored *StoredToken) Token {
+	return restoreCredentialToken(stored, g.client.now)
+}
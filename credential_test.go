@@ -0,0 +1,190 @@
+package vrest
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAzureManagedIdentity(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.Header.Get("Metadata") != "true" ||
+			query.Get("api-version") != DefaultAzureIMDSAPIVersion ||
+			query.Get("client_id") != "identity" ||
+			query.Has("resource") && query.Get("resource") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		expiresOn := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token-` + query.Get("resource") +
+			`","expires_in":"3600","expires_on":"` + expiresOn + `"}`))
+	}))
+	defer ts.Close()
+
+	c := NewWithClient(ts.Client()).
+		SetClock(func() time.Time { return now }).
+		SetAzureManagedIdentity(AzureManagedIdentityConfig{
+			Endpoint: ts.URL,
+			Resource: "default",
+			ClientID: "identity",
+		})
+
+	for _, scope := range []TokenScope{{}, {Resource: "vault"}} {
+		entry, err := c.getValidToken(context.Background(), scope)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := "token-" + scope.Resource
+		if scope.Resource == "" {
+			want = "token-default"
+		}
+		if entry.token.Token() != want {
+			t.Fatalf("unexpected token: %s, want %s", entry.token.Token(), want)
+		}
+		if expiresAt := entry.token.(ExpiringToken).ExpiresAt(); !expiresAt.Equal(now.Add(55 * time.Minute)) {
+			t.Fatalf("unexpected expiry: %v", expiresAt)
+		}
+	}
+
+	// without resource, the parameter is not sent
+	c.TokenGetter = &azureManagedIdentityGetter{
+		config: AzureManagedIdentityConfig{Endpoint: ts.URL, ClientID: "identity"},
+		client: c,
+	}
+	if _, err := c.TokenGetter.GetToken(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestKubernetesTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	writeToken := func(token string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	jwt := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(
+		[]byte(`{"exp":`+strconv.FormatInt(now.Add(time.Hour).Unix(), 10)+`}`)) + ".sig"
+	writeToken(jwt, now)
+
+	clock := now
+	c := New().
+		SetClock(func() time.Time { return clock }).
+		SetKubernetesTokenFile(path)
+
+	entry, err := c.getValidToken(context.Background(), TokenScope{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.token.Token() != jwt || entry.token.NeedsRefresh() {
+		t.Fatalf("unexpected token: %s", entry.token.Token())
+	}
+
+	if expiresAt := entry.token.(ExpiringToken).ExpiresAt(); !expiresAt.Equal(now.Add(55 * time.Minute)) {
+		t.Fatalf("token must be refreshed before exp, expires at %v", expiresAt)
+	}
+
+	// the rotated file is read again, after the check interval
+	writeToken("rotated", now.Add(time.Minute))
+	if entry.token.NeedsRefresh() {
+		t.Fatal("token file must not be checked before the check interval")
+	}
+	clock = now.Add(tokenFileCheckInterval)
+	if !entry.token.NeedsRefresh() {
+		t.Fatal("token must be refreshed after the file has changed")
+	}
+	if entry, err = c.getValidToken(context.Background(), TokenScope{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.token.Token() != "rotated" {
+		t.Fatalf("unexpected token: %s", entry.token.Token())
+	}
+}
+
+func TestExecCredential(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		output        string
+		wantErr       bool
+		wantToken     string
+		wantExpiresAt time.Time
+	}{
+		{
+			name:          "access token",
+			output:        `{"access_token":"token","expires_in":3600}`,
+			wantToken:     "token",
+			wantExpiresAt: now.Add(55 * time.Minute),
+		},
+		{
+			name:          "expires_at takes precedence",
+			output:        `{"access_token":"token","expires_in":60,"expires_at":"2024-05-01T13:00:00Z"}`,
+			wantToken:     "token",
+			wantExpiresAt: now.Add(55 * time.Minute),
+		},
+		{
+			name:          "kubernetes exec credential",
+			output:        `{"kind":"ExecCredential","status":{"token":"token","expirationTimestamp":"2024-05-01T13:00:00Z"}}`,
+			wantToken:     "token",
+			wantExpiresAt: now.Add(55 * time.Minute),
+		},
+		{
+			name:      "no expiry",
+			output:    `{"access_token":"token"}`,
+			wantToken: "token",
+		},
+		{
+			name:    "no token",
+			output:  `{}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New().
+				SetClock(func() time.Time { return now }).
+				SetExecCredential(ExecCredentialConfig{
+					Command: "sh",
+					Args:    []string{"-c", `printf '%s' "$OUTPUT"`},
+					Env:     []string{"OUTPUT=" + tt.output},
+				})
+
+			token, err := c.TokenGetter.GetToken(context.Background(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+
+			if token.Token() != tt.wantToken || token.NeedsRefresh() {
+				t.Fatalf("unexpected token: %s", token.Token())
+			}
+			if expiresAt := token.(ExpiringToken).ExpiresAt(); !expiresAt.Equal(tt.wantExpiresAt) {
+				t.Fatalf("unexpected expiry: %v, want %v", expiresAt, tt.wantExpiresAt)
+			}
+		})
+	}
+}
@@ -7,20 +7,52 @@ import (
 )
 
 // Do sends the request.
-// If the client has a trace maker set, it will create a trace.
+// If the client has a trace maker set, it will create a trace for each attempt.
+// If the request has a retry policy, failed attempts are retried
+// according to the policy. If the server rejects the token of the
+// token getter, the request is replayed once with a new token.
 func Do(req *Request) error {
 	err := req.makeHTTPRequest()
 	if err != nil {
 		return err
 	}
 
+	for {
+		req.Attempt++
+		err = req.doAttempt()
+
+		if req.shouldReauthenticate(err) {
+			if err = req.reauthenticate(err); err != nil {
+				return err
+			}
+			req.prepareRetry()
+			continue
+		}
+
+		wait, retry := req.retryWait(err)
+		if !retry {
+			return err
+		}
+
+		if sleepErr := sleepContext(req.Context, wait); sleepErr != nil {
+			return err
+		}
+		req.prepareRetry()
+	}
+}
+
+// doAttempt sends the already built HTTP request once
+// and processes the response.
+func (req *Request) doAttempt() error {
 	var trace Trace
 	if req.Client.traceMaker != nil {
 		trace = req.Client.traceMaker.NewTrace(req)
 		defer trace.End()
 	}
 
-	req.Response.Raw, err = req.Overridable.DoHTTPRequest(req) // nolint:bodyclose
+	var err error
+	doHTTPRequest := chainHTTPDoer(req.HTTPMiddlewares, req.Overridable.DoHTTPRequest)
+	req.Response.Raw, err = doHTTPRequest(req) // nolint:bodyclose
 	if req.shouldCloseResponseBody() {
 		defer req.Client.closeRawResponse(req)
 	}
@@ -171,6 +203,8 @@ func (req *Request) Dof(method, pathFormat string, values ...any) error {
 }
 
 // Do sends the request with the given method.
+// The path is appended to the base URL, unless it is an absolute
+// http or https URL, like a link returned by the server.
 func (req *Request) Do(method, path string) error {
 	req.Method = method
 	req.Path = path
@@ -179,7 +213,7 @@ func (req *Request) Do(method, path string) error {
 		return err
 	}
 
-	return req.Client.Overridable.Do(req)
+	return chainDoer(req.Middlewares, req.Client.Overridable.Do)(req)
 }
 
 // DoHTTPRequest sends the request using the http.Client.
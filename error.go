@@ -0,0 +1,105 @@
+package vrest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTPError is returned by a request if the response is not successful.
+// Use errors.As to access it. If the error body was unmarshaled into a
+// type implementing the error interface (see Client.SetErrorBodyType),
+// it is wrapped and can be accessed with errors.As as well.
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+
+	// Body contains the raw response body bytes.
+	Body []byte
+
+	// ErrorBody is the unmarshaled error body.
+	// It is nil if the error body was not unmarshaled.
+	ErrorBody any
+}
+
+// Error returns the error message including the status code and the error body.
+func (e *HTTPError) Error() string {
+	if e.ErrorBody != nil {
+		errMsg := fmt.Sprintf("http request %s %s failed: status %d", e.Method, e.URL, e.StatusCode)
+		switch body := e.ErrorBody.(type) {
+		case error:
+			return fmt.Sprintf("%s: %s", errMsg, body.Error())
+		default:
+			return fmt.Sprintf("%s: %s", errMsg, body)
+		}
+	}
+
+	if e.Body == nil {
+		return fmt.Sprintf("http request %s %s failed with status code %d", e.Method, e.URL, e.StatusCode)
+	}
+
+	return fmt.Sprintf("http request %s %s failed: status %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// Unwrap returns the error body, if it implements the error interface.
+func (e *HTTPError) Unwrap() error {
+	if err, ok := e.ErrorBody.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// StatusCodeOf returns the status code of an HTTPError in the error chain.
+// It returns 0 if err does not contain an HTTPError.
+func StatusCodeOf(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err contains an HTTPError with status 404.
+func IsNotFound(err error) bool {
+	return StatusCodeOf(err) == http.StatusNotFound
+}
+
+// IsConflict reports whether err contains an HTTPError with status 409.
+func IsConflict(err error) bool {
+	return StatusCodeOf(err) == http.StatusConflict
+}
+
+// IsUnauthorized reports whether err contains an HTTPError with status 401.
+func IsUnauthorized(err error) bool {
+	return StatusCodeOf(err) == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether err contains an HTTPError with status 403.
+func IsForbidden(err error) bool {
+	return StatusCodeOf(err) == http.StatusForbidden
+}
+
+// IsClientError reports whether err contains an HTTPError with a 4xx status.
+func IsClientError(err error) bool {
+	statusCode := StatusCodeOf(err)
+	return statusCode >= 400 && statusCode < 500
+}
+
+// IsServerError reports whether err contains an HTTPError with a 5xx status.
+func IsServerError(err error) bool {
+	statusCode := StatusCodeOf(err)
+	return statusCode >= 500 && statusCode < 600
+}
+
+func (req *Request) newHTTPError(errorBody any) *HTTPError {
+	return &HTTPError{
+		Method:     req.Raw.Method,
+		URL:        req.Raw.URL.String(),
+		StatusCode: req.Response.StatusCode(),
+		Header:     req.Response.Header(),
+		Body:       req.Response.BodyBytes,
+		ErrorBody:  errorBody,
+	}
+}
@@ -0,0 +1,68 @@
+package vrest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type testErrorBody struct {
+	Message string `json:"message"`
+}
+
+func (e *testErrorBody) Error() string {
+	return e.Message
+}
+
+func TestHTTPError(t *testing.T) {
+	c := New().SetErrorBodyType(&testErrorBody{})
+	c.Overridable.DoHTTPRequest = MockHTTPDoer(
+		MockJSONResponse(http.StatusNotFound, `{"message":"order not found"}`),
+		"X-Request-Id", "abc",
+	)
+
+	err := c.NewRequest().DoGet("https://example.com/orders/1")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected *HTTPError, got %T", err)
+	}
+	if httpErr.StatusCode != http.StatusNotFound || httpErr.Header.Get("X-Request-Id") != "abc" {
+		t.Fatalf("unexpected error fields: %+v", httpErr)
+	}
+	if !IsNotFound(err) || !IsClientError(err) || IsServerError(err) {
+		t.Fatalf("unexpected status helpers result for %v", err)
+	}
+
+	var errBody *testErrorBody
+	if !errors.As(err, &errBody) || errBody.Message != "order not found" {
+		t.Fatalf("error body not accessible: %v", err)
+	}
+
+	want := "http request GET https://example.com/orders/1 failed: status 404: order not found"
+	if err.Error() != want {
+		t.Fatalf("unexpected error message:\ngot:  %s\nwant: %s", err, want)
+	}
+}
+
+func TestErrorBody(t *testing.T) {
+	type problem struct {
+		Title string `json:"title"`
+	}
+
+	c := New()
+	c.Overridable.DoHTTPRequest = MockHTTPDoer(MockJSONResponse(http.StatusConflict, `{"title":"duplicate"}`))
+
+	_, err := Post[map[string]any](SetErrorBody[problem](c.NewRequest()), "/orders")
+	if !IsConflict(err) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	body, ok := ErrorBody[problem](err)
+	if !ok || body.Title != "duplicate" {
+		t.Fatalf("unexpected error body: %v %+v", ok, body)
+	}
+}
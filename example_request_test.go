@@ -2,7 +2,6 @@ package vrest_test
 
 import (
 	"fmt"
-	"net/url"
 
 	"github.com/fond-of-vertigo/vrest"
 )
@@ -27,10 +26,11 @@ func ExampleRequest_DoPost() {
 	client := vrest.New()
 	respBody := make(map[string]interface{})
 
-	body := url.Values{}
-	body.Set("title", "foo")
-	body.Set("body", "bar")
-	body.Set("userId", "1")
+	body := map[string]any{
+		"title":  "foo",
+		"body":   "bar",
+		"userId": 1,
+	}
 
 	err := client.NewRequest().
 		SetResponseBody(&respBody).
@@ -43,7 +43,7 @@ func ExampleRequest_DoPost() {
 	}
 
 	fmt.Println("Response:", respBody)
-	// Output: Response: map[body:[bar] id:101 title:[foo] userId:[1]]
+	// Output: Response: map[body:bar id:101 title:foo userId:1]
 }
 
 func ExampleRequest_SetBasicAuth() {
@@ -27,9 +27,18 @@ func (o *Tracer) NewTrace(req *vrest.Request) vrest.Trace {
 			attribute.String("http.url", httpReq.URL.String()),
 			attribute.String("http.header", fmt.Sprintf("%v", headerWithoutAuth(httpReq.Header))),
 			attribute.String("http.body", string(req.BodyBytes)),
+			attribute.Int("http.request.resend_count", req.Attempt-1),
+			attribute.Bool("http.request.reauthenticated", req.Reauthenticated),
 		),
 	)
 
+	for i, part := range req.MultipartParts {
+		// only the metadata is traced, never the file contents
+		span.SetAttributes(attribute.String(fmt.Sprintf("http.multipart_part.%d", i),
+			fmt.Sprintf("name=%s filename=%s content_type=%s size=%d",
+				part.Name, part.FileName, part.ContentType, part.Size)))
+	}
+
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 	return &Trace{span: span}
 }
@@ -47,6 +56,10 @@ func (o *Trace) OnAfterRequest(req *vrest.Request) {
 		attribute.Int("http.status_code", req.Response.StatusCode()),
 		attribute.String("http.response_header", fmt.Sprintf("%v", headerWithoutAuth(req.Response.Header()))),
 		attribute.String("http.response_body", string(req.Response.BodyBytes)),
+		attribute.Int64("http.response_body_size", req.Response.BodySize),
+		attribute.Bool("http.response_body_truncated", req.Response.Truncated),
+		attribute.String("http.response.cache_status", string(req.Response.CacheStatus)),
+		attribute.String("http.request.circuit_state", string(req.CircuitState)),
 	)
 }
 
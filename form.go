@@ -0,0 +1,166 @@
+package vrest
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EncodeForm converts v into form values. Supported are url.Values,
+// map[string][]string, map[string]string and structs or pointers to structs.
+//
+// Struct fields are encoded with the name given in the "form" tag,
+// or with the field name if there is no tag. The option "omitempty"
+// skips zero values, the name "-" skips the field entirely:
+//
+//	type Login struct {
+//		User     string `form:"user"`
+//		Remember bool   `form:"remember,omitempty"`
+//		Internal string `form:"-"`
+//	}
+//
+// Field values can be strings, bools, numbers, encoding.TextMarshaler
+// and fmt.Stringer values, pointers to them and slices of them.
+// Slices are encoded as repeated keys. The fields of embedded structs
+// and pointers to structs without tag are promoted, like with encoding/json.
+// Unexported fields, including embedded fields with a tag, are skipped.
+func EncodeForm(v any) (url.Values, error) {
+	switch values := v.(type) {
+	case url.Values:
+		return values, nil
+	case map[string][]string:
+		return values, nil
+	case map[string]string:
+		form := make(url.Values, len(values))
+		for key, value := range values {
+			form.Set(key, value)
+		}
+		return form, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("can't encode %T as form", v)
+	}
+
+	form := make(url.Values)
+	if err := encodeFormStruct(form, rv); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+func encodeFormStruct(form url.Values, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		tag := field.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		omitEmpty := opts == "omitempty"
+		value := rv.Field(i)
+
+		if name == "" && isEmbeddedStruct(field) {
+			// exported fields of embedded structs are promoted,
+			// even if the embedded type is unexported
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if err := encodeFormStruct(form, value); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if omitEmpty && value.IsZero() {
+			continue
+		}
+
+		if err := encodeFormField(form, name, value); err != nil {
+			return fmt.Errorf("form field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// isEmbeddedStruct reports whether the field is an embedded struct or pointer to a struct.
+func isEmbeddedStruct(field reflect.StructField) bool {
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return field.Anonymous && t.Kind() == reflect.Struct
+}
+
+func encodeFormField(form url.Values, name string, value reflect.Value) error {
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 {
+		for i := range value.Len() {
+			if err := encodeFormField(form, name, value.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	text, err := formValueString(value)
+	if err != nil {
+		return err
+	}
+	form.Add(name, text)
+	return nil
+}
+
+func formValueString(value reflect.Value) (string, error) {
+	if value.CanInterface() {
+		switch v := value.Interface().(type) {
+		case encoding.TextMarshaler:
+			text, err := v.MarshalText()
+			return string(text), err
+		case fmt.Stringer:
+			return v.String(), nil
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits()), nil
+	case reflect.Slice:
+		// only []byte reaches this point
+		return string(value.Bytes()), nil
+	default:
+		return "", fmt.Errorf("unsupported type %s", value.Type())
+	}
+}
@@ -0,0 +1,111 @@
+package vrest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeForm(t *testing.T) {
+	type paging struct {
+		Page int `form:"page"`
+	}
+	type search struct {
+		paging
+		Query    string    `form:"q"`
+		Tags     []string  `form:"tag"`
+		Limit    *int      `form:"limit,omitempty"`
+		Exact    bool      `form:"exact,omitempty"`
+		Since    time.Time `form:"since"`
+		Internal string    `form:"-"`
+		Name     string
+	}
+
+	values, err := EncodeForm(&search{
+		paging:   paging{Page: 2},
+		Query:    "a&b",
+		Tags:     []string{"x", "y"},
+		Since:    mustParseTime(t, testTimeValue),
+		Internal: "secret",
+		Name:     "n",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "Name=n&page=2&q=a%26b&since=2024-04-23T09%3A26%3A44.995288%2B02%3A00&tag=x&tag=y"
+	if got := values.Encode(); got != want {
+		t.Fatalf("unexpected form:\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestEncodeForm_embedded(t *testing.T) {
+	type audit struct {
+		Created time.Time `form:"created"`
+	}
+	type Owner struct {
+		Owner string `form:"owner"`
+	}
+	type timestamp struct {
+		time.Time
+	}
+	created := mustParseTime(t, testTimeValue)
+
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{
+			name: "pointer to unexported struct",
+			value: struct {
+				*audit
+				*Owner
+			}{&audit{Created: created}, &Owner{Owner: "me"}},
+			want: "created=2024-04-23T09%3A26%3A44.995288%2B02%3A00&owner=me",
+		},
+		{
+			name: "nil pointer",
+			value: struct {
+				*audit
+				Name string `form:"name"`
+			}{Name: "n"},
+			want: "name=n",
+		},
+		{
+			name: "unexported struct with tag",
+			value: struct {
+				timestamp `form:"ts"`
+				Name      string `form:"name"`
+			}{timestamp{created}, "n"},
+			want: "name=n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := EncodeForm(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := values.Encode(); got != tt.want {
+				t.Fatalf("unexpected form:\ngot:  %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequest_SetFormData(t *testing.T) {
+	c := New()
+	mock := &MockHTTPResponse{}
+	c.Overridable.DoHTTPRequest = MockHTTPDoer(mock)
+
+	err := c.NewRequest().
+		SetFormData(map[string]string{"grant_type": "client_credentials"}).
+		DoPost("/token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := string(mock.CapturedRequest.BodyBytes); got != "grant_type=client_credentials" {
+		t.Fatalf("unexpected request body %q", got)
+	}
+}
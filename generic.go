@@ -0,0 +1,76 @@
+package vrest
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DoAs sends the request with the given method and returns the
+// response body unmarshaled into a value of type T.
+// T can be any type that can be passed as pointer to SetResponseBody,
+// including []byte and io.ReadCloser.
+func DoAs[T any](req *Request, method, path string) (T, error) {
+	var value T
+	err := req.SetResponseBody(&value).Do(method, path)
+	return value, err
+}
+
+// Get sends the request with the GET method and returns the
+// response body unmarshaled into a value of type T.
+func Get[T any](req *Request, path string) (T, error) {
+	return DoAs[T](req, http.MethodGet, path)
+}
+
+// Post sends the request with the POST method and returns the
+// response body unmarshaled into a value of type T.
+func Post[T any](req *Request, path string) (T, error) {
+	return DoAs[T](req, http.MethodPost, path)
+}
+
+// Put sends the request with the PUT method and returns the
+// response body unmarshaled into a value of type T.
+func Put[T any](req *Request, path string) (T, error) {
+	return DoAs[T](req, http.MethodPut, path)
+}
+
+// Patch sends the request with the PATCH method and returns the
+// response body unmarshaled into a value of type T.
+func Patch[T any](req *Request, path string) (T, error) {
+	return DoAs[T](req, http.MethodPatch, path)
+}
+
+// Delete sends the request with the DELETE method and returns the
+// response body unmarshaled into a value of type T.
+func Delete[T any](req *Request, path string) (T, error) {
+	return DoAs[T](req, http.MethodDelete, path)
+}
+
+// SetErrorBody sets a new value of type E as the error body of the request.
+// Use ErrorBody to get the unmarshaled value from the returned error.
+func SetErrorBody[E any](req *Request) *Request {
+	return req.SetResponseErrorBody(new(E))
+}
+
+// ErrorBody returns the unmarshaled error body of type E from an
+// HTTPError in the error chain. It returns false if err does not
+// contain an HTTPError or the error body is not of type E or *E.
+func ErrorBody[E any](err error) (E, bool) {
+	var zero E
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return zero, false
+	}
+
+	switch body := httpErr.ErrorBody.(type) {
+	case *E:
+		if body == nil {
+			return zero, false
+		}
+		return *body, true
+	case E:
+		return body, true
+	default:
+		return zero, false
+	}
+}
@@ -0,0 +1,167 @@
+package vrest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // nolint:gosec // x5t is defined as SHA-1 thumbprint
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const ecdsaP256KeySize = 32
+
+var ErrInvalidPrivateKey = errors.New("invalid private key")
+
+// jwtSigner signs JWTs with RS256 or ES256.
+type jwtSigner struct {
+	key crypto.Signer
+	alg string
+	x5t string
+	kid string
+}
+
+// newJWTSigner creates a signer from a PEM encoded private key.
+// The key can be a PKCS#1 RSA key, a SEC 1 EC key or a PKCS#8 key.
+// If certPEM is given, its SHA-1 thumbprint is set as x5t header.
+func newJWTSigner(keyPEM, certPEM []byte, keyID string) (*jwtSigner, error) {
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &jwtSigner{key: key, kid: keyID}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signer.alg = "RS256"
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: only EC keys with curve P-256 are supported", ErrInvalidPrivateKey)
+		}
+		signer.alg = "ES256"
+	}
+
+	if len(certPEM) > 0 {
+		block, _ := pem.Decode(certPEM)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, errors.New("invalid PEM certificate")
+		}
+		thumbprint := sha1.Sum(block.Bytes) // nolint:gosec
+		signer.x5t = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	}
+
+	return signer, nil
+}
+
+func parsePrivateKey(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrInvalidPrivateKey)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case *ecdsa.PrivateKey:
+			return k, nil
+		}
+		return nil, fmt.Errorf("%w: unsupported key type %T", ErrInvalidPrivateKey, key)
+	default:
+		return nil, fmt.Errorf("%w: unsupported PEM block type %q", ErrInvalidPrivateKey, block.Type)
+	}
+}
+
+// sign returns the signed compact JWT with the given claims.
+func (s *jwtSigner) sign(claims map[string]any) (string, error) {
+	header := map[string]string{
+		"alg": s.alg,
+		"typ": "JWT",
+	}
+	if s.x5t != "" {
+		header["x5t"] = s.x5t
+	}
+	if s.kid != "" {
+		header["kid"] = s.kid
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		signature, err = signES256(key, digest[:])
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// signES256 returns the signature as fixed size r || s, as required by JWS.
+func signES256(key *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, 2*ecdsaP256KeySize)
+	r.FillBytes(signature[:ecdsaP256KeySize])
+	s.FillBytes(signature[ecdsaP256KeySize:])
+	return signature, nil
+}
+
+// jwtExpiry returns the exp claim of a JWT. The signature is not verified,
+// because the token is only used to learn its expiry.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { // nolint:mnd // header, payload and signature
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(claims.Exp), 0), true
+}
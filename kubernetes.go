@@ -0,0 +1,93 @@
+package vrest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultKubernetesTokenFile is the path of the service account token
+// that Kubernetes mounts into pods by default.
+const DefaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// tokenFileCheckInterval is the minimum interval between
+// two checks whether the token file has changed.
+const tokenFileCheckInterval = 10 * time.Second
+
+type kubernetesTokenGetter struct {
+	path   string
+	client *Client
+}
+
+// SetKubernetesTokenFile sets a token getter that reads a Kubernetes
+// projected service account token from the given file. If path is empty,
+// DefaultKubernetesTokenFile is used. The kubelet rotates the file before
+// the token expires, so the file is read again when it has changed or
+// when the exp claim of the token is reached. The file is checked for
+// changes at most every 10 seconds.
+func (c *Client) SetKubernetesTokenFile(path string) *Client {
+	if path == "" {
+		path = DefaultKubernetesTokenFile
+	}
+
+	return c.SetTokenGetter(&kubernetesTokenGetter{
+		path:   path,
+		client: c,
+	})
+}
+
+// GetToken reads the token file.
+func (g *kubernetesTokenGetter) GetToken(_ context.Context, _ Token) (Token, error) {
+	info, err := os.Stat(g.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	data, err := os.ReadFile(g.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	token := &credentialToken{
+		value:   strings.TrimSpace(string(data)),
+		clock:   g.client.now,
+		changed: fileChanged(g.path, info.ModTime(), g.client.now),
+	}
+	if token.value == "" {
+		return nil, fmt.Errorf("service account token file %s is empty", g.path)
+	}
+	if exp, ok := jwtExpiry(token.value); ok {
+		token.validUntil = validUntil(g.client.now(), exp, 0, 0)
+	}
+	return token, nil
+}
+
+// fileChanged returns a function that reports whether the file
+// was modified or replaced since the given modification time.
+// It is called for every request, so the file is checked at most
+// once per tokenFileCheckInterval and without locking.
+func fileChanged(path string, modTime time.Time, clock func() time.Time) func() bool {
+	var changed atomic.Bool
+	var nextCheck atomic.Int64
+	nextCheck.Store(clock().Add(tokenFileCheckInterval).UnixNano())
+
+	return func() bool {
+		if changed.Load() {
+			return true
+		}
+
+		now := clock()
+		next := nextCheck.Load()
+		if now.UnixNano() < next || !nextCheck.CompareAndSwap(next, now.Add(tokenFileCheckInterval).UnixNano()) {
+			return false
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			changed.Store(true)
+		}
+		return changed.Load()
+	}
+}
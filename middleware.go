@@ -0,0 +1,82 @@
+package vrest
+
+import (
+	"net/http"
+	"slices"
+)
+
+type (
+	// Middleware wraps the execution of a whole request, including all
+	// retries. It must call next to continue with the request.
+	Middleware func(req *Request, next Doer) error
+
+	// HTTPMiddleware wraps a single HTTP round trip. If a request is
+	// retried, it is called for each attempt. It must call next to
+	// continue with the round trip.
+	HTTPMiddleware func(req *Request, next HTTPDoer) (*http.Response, error)
+)
+
+// Use adds middlewares that wrap Overridable.Do for all requests of the client.
+// Middlewares are called in the order they were added, the first one is the
+// outermost. Client middlewares are always called before request middlewares.
+func (c *Client) Use(middlewares ...Middleware) *Client {
+	c.Middlewares = append(c.Middlewares, middlewares...)
+	return c
+}
+
+// UseHTTP adds middlewares that wrap Overridable.DoHTTPRequest for all requests
+// of the client. Middlewares are called in the order they were added, the first
+// one is the outermost. Client middlewares are always called before request
+// middlewares.
+func (c *Client) UseHTTP(middlewares ...HTTPMiddleware) *Client {
+	c.HTTPMiddlewares = append(c.HTTPMiddlewares, middlewares...)
+	return c
+}
+
+// Use adds middlewares that wrap Overridable.Do for this request.
+// They are called after the middlewares of the client.
+func (req *Request) Use(middlewares ...Middleware) *Request {
+	req.Middlewares = append(req.Middlewares, middlewares...)
+	return req
+}
+
+// UseHTTP adds middlewares that wrap Overridable.DoHTTPRequest for this request.
+// They are called after the middlewares of the client.
+func (req *Request) UseHTTP(middlewares ...HTTPMiddleware) *Request {
+	req.HTTPMiddlewares = append(req.HTTPMiddlewares, middlewares...)
+	return req
+}
+
+// chainDoer returns a Doer that calls the middlewares in order and do at last.
+func chainDoer(middlewares []Middleware, do Doer) Doer {
+	for _, mw := range slices.Backward(middlewares) {
+		next := do
+		do = func(req *Request) error {
+			return mw(req, next)
+		}
+	}
+	return do
+}
+
+// chainHTTPDoer returns an HTTPDoer that calls the middlewares in order and do at last.
+func chainHTTPDoer(middlewares []HTTPMiddleware, do HTTPDoer) HTTPDoer {
+	for _, mw := range slices.Backward(middlewares) {
+		next := do
+		do = func(req *Request) (*http.Response, error) {
+			return mw(req, next)
+		}
+	}
+	return do
+}
+
+// KeyByHost returns the host of the request. It can be used as key
+// function of the rate limiter and the circuit breaker.
+func KeyByHost(req *Request) string {
+	return req.Raw.URL.Host
+}
+
+// KeyByRoute returns the method, host and path of the request. It can be
+// used as key function of the rate limiter and the circuit breaker.
+func KeyByRoute(req *Request) string {
+	return req.Raw.Method + " " + req.Raw.URL.Host + req.Raw.URL.Path
+}
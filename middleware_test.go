@@ -0,0 +1,51 @@
+package vrest
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMiddleware_order(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(req *Request, next Doer) error {
+			calls = append(calls, name)
+			return next(req)
+		}
+	}
+	httpMW := func(name string) HTTPMiddleware {
+		return func(req *Request, next HTTPDoer) (*http.Response, error) {
+			calls = append(calls, name)
+			return next(req)
+		}
+	}
+
+	c := New().
+		Use(mw("client 1"), mw("client 2")).
+		UseHTTP(httpMW("client http"))
+	c.Overridable.DoHTTPRequest = MockHTTPDoer(&MockHTTPResponse{})
+
+	err := c.NewRequest().
+		Use(mw("request")).
+		UseHTTP(httpMW("request http")).
+		DoGet("/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"client 1", "client 2", "request", "client http", "request http"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("unexpected order:\ngot:  %v\nwant: %v", calls, want)
+	}
+
+	// request middlewares must not leak into the client
+	calls = nil
+	if err = c.NewRequest().DoGet("/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []string{"client 1", "client 2", "client http"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("unexpected order:\ngot:  %v\nwant: %v", calls, want)
+	}
+}
@@ -0,0 +1,189 @@
+package vrest
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// MultipartPart is a part of a multipart/form-data request body.
+// Traces can access the part metadata with Request.MultipartParts,
+// but they must not read from Reader.
+type MultipartPart struct {
+	// Name is the form field name.
+	Name string
+
+	// FileName is the name of the uploaded file.
+	// It is empty for regular form fields.
+	FileName string
+
+	// ContentType is the content type of the part.
+	// For files it defaults to "application/octet-stream".
+	ContentType string
+
+	// Size is the size of the content in bytes, or -1 if unknown.
+	// If the sizes of all parts are known, the Content-Length
+	// of the request is set.
+	Size int64
+
+	// Reader provides the content of the part. It is read when the
+	// request is sent. vrest does not close the reader.
+	Reader io.Reader
+}
+
+// AddMultipartField adds a form field to the multipart/form-data body of the request.
+func (req *Request) AddMultipartField(name, value string) *Request {
+	return req.AddMultipartPart(MultipartPart{
+		Name:   name,
+		Size:   int64(len(value)),
+		Reader: strings.NewReader(value),
+	})
+}
+
+// AddMultipartFile adds a file to the multipart/form-data body of the request.
+// The content is streamed from reader when the request is sent.
+func (req *Request) AddMultipartFile(name, fileName string, reader io.Reader) *Request {
+	return req.AddMultipartPart(MultipartPart{
+		Name:        name,
+		FileName:    fileName,
+		ContentType: "application/octet-stream",
+		Reader:      reader,
+	})
+}
+
+// AddMultipartPart adds a custom part to the multipart/form-data body of the request.
+// If the size of the part is 0, it is detected from the reader if possible.
+func (req *Request) AddMultipartPart(part MultipartPart) *Request {
+	if part.Size == 0 && part.Reader != nil {
+		part.Size = readerSize(part.Reader)
+	}
+	req.MultipartParts = append(req.MultipartParts, part)
+	return req
+}
+
+// prepareMultipartBody sets the body, the Content-Type including
+// the boundary and, if possible, the Content-Length of the request.
+// The parts are streamed through a pipe when the request is sent.
+func (req *Request) prepareMultipartBody() error {
+	if req.Body != nil {
+		return fmt.Errorf("%w: a request can't have a body and multipart parts", ErrInvalidRequest)
+	}
+
+	parts := req.MultipartParts
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	req.SetContentType("multipart/form-data; boundary=" + boundary)
+	if req.ContentLength <= 0 {
+		req.ContentLength = multipartContentLength(boundary, parts)
+	}
+	req.Body = newPipeBody(func(w io.Writer) error {
+		return writeMultipart(w, boundary, parts)
+	})
+	return nil
+}
+
+// resetMultipartBody removes the body created by prepareMultipartBody.
+func (req *Request) resetMultipartBody() {
+	if body, ok := req.Body.(*pipeBody); ok {
+		_ = body.Close()
+	}
+	req.Body = nil
+	req.Raw = nil
+}
+
+func writeMultipart(w io.Writer, boundary string, parts []MultipartPart) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		partWriter, err := mw.CreatePart(part.header())
+		if err != nil {
+			return err
+		}
+		if part.Reader != nil {
+			if _, err = io.Copy(partWriter, part.Reader); err != nil {
+				return err
+			}
+		}
+	}
+
+	return mw.Close()
+}
+
+// multipartContentLength returns the length of the whole body,
+// or 0 if the size of at least one part is unknown.
+func multipartContentLength(boundary string, parts []MultipartPart) int64 {
+	counter := &countingWriter{}
+	mw := multipart.NewWriter(counter)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return 0
+	}
+
+	var size int64
+	for _, part := range parts {
+		if part.Size < 0 {
+			return 0
+		}
+		if _, err := mw.CreatePart(part.header()); err != nil {
+			return 0
+		}
+		size += part.Size
+	}
+	if err := mw.Close(); err != nil {
+		return 0
+	}
+
+	return counter.n + size
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (part *MultipartPart) header() textproto.MIMEHeader {
+	disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(part.Name))
+	if part.FileName != "" {
+		disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(part.FileName))
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", disposition)
+	if part.ContentType != "" {
+		header.Set("Content-Type", part.ContentType)
+	}
+	return header
+}
+
+// readerSize returns the remaining size of the reader, or -1 if unknown.
+func readerSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case io.Seeker:
+		current, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := v.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err = v.Seek(current, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - current
+	default:
+		return -1
+	}
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,81 @@
+package vrest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequest_AddMultipartFile(t *testing.T) {
+	tests := []struct {
+		name              string
+		file              io.Reader
+		wantContentLength bool
+	}{
+		{"known size", strings.NewReader("file content"), true},
+		{"unknown size", io.MultiReader(strings.NewReader("file content")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if (r.ContentLength > 0) != tt.wantContentLength {
+					t.Errorf("unexpected content length %d", r.ContentLength)
+				}
+
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("failed to parse multipart form: %v", err)
+					return
+				}
+				if got := r.FormValue("description"); got != "invoice" {
+					t.Errorf("unexpected field value %q", got)
+				}
+
+				file, header, err := r.FormFile("document")
+				if err != nil {
+					t.Errorf("missing file: %v", err)
+					return
+				}
+				defer file.Close()
+				content, _ := io.ReadAll(file)
+				if header.Filename != "invoice.pdf" || string(content) != "file content" {
+					t.Errorf("unexpected file %s: %q", header.Filename, content)
+				}
+			}))
+			defer ts.Close()
+
+			err := NewWithClient(ts.Client()).
+				SetBaseURL(ts.URL).
+				SetContentTypeJSON().
+				NewRequest().
+				AddMultipartField("description", "invoice").
+				AddMultipartFile("document", "invoice.pdf", tt.file).
+				DoPost("/upload")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequest_multipartResendAfterError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil || r.FormValue("description") != "invoice" {
+			t.Errorf("unexpected multipart body: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	req := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		NewRequest().
+		AddMultipartField("description", "invoice")
+
+	if err := req.DoPost("/invalid\x7f"); err == nil {
+		t.Fatal("expected invalid URL error")
+	}
+	if err := req.DoPost("/upload"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
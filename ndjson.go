@@ -0,0 +1,119 @@
+package vrest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// ContentTypeNDJSON is the content type of newline-delimited JSON.
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// DoNDJSON sends the request with the given method and returns an iterator
+// that decodes the newline-delimited JSON response record by record.
+// The response body is streamed and not buffered into Response.BodyBytes,
+// so Response.BodyLimit does not apply. Empty lines are skipped.
+// The records are unmarshaled with Overridables.JSONUnmarshal.
+//
+// The request is sent when the iteration starts. If the request or
+// the decoding of a record fails, the error is yielded and the
+// iteration stops.
+func DoNDJSON[T any](req *Request, method, path string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		var stream io.ReadCloser
+		req.SetHeaderIf(req.Header.Get("Accept") == "", "Accept", ContentTypeNDJSON)
+		if err := req.SetResponseBody(&stream).Do(method, path); err != nil {
+			yield(zero, err)
+			return
+		}
+		defer req.Client.closeRawResponse(req)
+
+		reader := bufio.NewReader(stream)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(zero, fmt.Errorf("http request %s %s failed to read response body: %w",
+					req.Raw.Method, req.Raw.URL, err))
+				return
+			}
+
+			if record := bytes.TrimSpace(line); len(record) > 0 {
+				var value T
+				if unmarshalErr := req.Overridable.JSONUnmarshal(req, record, &value); unmarshalErr != nil {
+					yield(zero, fmt.Errorf("http request %s %s failed to unmarshal response record: %w",
+						req.Raw.Method, req.Raw.URL, unmarshalErr))
+					return
+				}
+				if !yield(value, nil) {
+					return
+				}
+			}
+
+			if err != nil {
+				// io.EOF
+				return
+			}
+		}
+	}
+}
+
+// GetNDJSON sends the request with the GET method and returns an iterator
+// that decodes the newline-delimited JSON response record by record.
+// See DoNDJSON for details.
+func GetNDJSON[T any](req *Request, path string) iter.Seq2[T, error] {
+	return DoNDJSON[T](req, http.MethodGet, path)
+}
+
+// SetNDJSONBody sets the request body to the values of the iterator,
+// encoded as newline-delimited JSON, and sets the Content-Type.
+// The values are marshaled with Overridables.JSONMarshal and streamed
+// while the request is sent, the payload is never buffered as a whole.
+// The request can't be retried.
+func SetNDJSONBody[T any](req *Request, values iter.Seq[T]) *Request {
+	body := newPipeBody(func(w io.Writer) error {
+		for value := range values {
+			if err := writeNDJSONRecord(req, w, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return req.
+		SetContentType(ContentTypeNDJSON).
+		SetBody(body)
+}
+
+// SetNDJSONBodyFromChan sets the request body to the values received from
+// the channel, encoded as newline-delimited JSON, and sets the Content-Type.
+// The body ends when the channel is closed. See SetNDJSONBody for details.
+func SetNDJSONBodyFromChan[T any](req *Request, values <-chan T) *Request {
+	return SetNDJSONBody(req, func(yield func(T) bool) {
+		for value := range values {
+			if !yield(value) {
+				return
+			}
+		}
+	})
+}
+
+func writeNDJSONRecord(req *Request, w io.Writer, value any) error {
+	record, err := req.Overridable.JSONMarshal(req, value)
+	if err != nil {
+		return err
+	}
+
+	record = bytes.TrimRight(record, "\n")
+	if bytes.ContainsRune(record, '\n') {
+		return fmt.Errorf("marshaled NDJSON record of type %T contains a newline", value)
+	}
+
+	_, err = w.Write(append(record, '\n'))
+	return err
+}
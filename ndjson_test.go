@@ -0,0 +1,41 @@
+package vrest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type testRecord struct {
+	ID int `json:"id"`
+}
+
+func TestNDJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// echo the request body
+		w.Header().Set("Content-Type", ContentTypeNDJSON)
+		_, _ = io.Copy(w, r.Body)
+		_, _ = w.Write([]byte("\n"))
+	}))
+	defer ts.Close()
+
+	c := NewWithClient(ts.Client()).SetBaseURL(ts.URL)
+
+	sent := []testRecord{{ID: 1}, {ID: 2}, {ID: 3}}
+	req := SetNDJSONBody(c.NewRequest(), slices.Values(sent))
+
+	var received []testRecord
+	for record, err := range DoNDJSON[testRecord](req, http.MethodPost, "/echo") {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		received = append(received, record)
+	}
+
+	if !reflect.DeepEqual(received, sent) {
+		t.Fatalf("unexpected records:\ngot:  %v\nwant: %v", received, sent)
+	}
+}
@@ -1,13 +1,47 @@
 package vrest
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
-var ErrOAuthTokenRequestFailed = errors.New("failed to get new oauth token")
+var (
+	ErrOAuthTokenRequestFailed  = errors.New("failed to get new oauth token")
+	ErrInteractiveGrantRequired = errors.New("interactive oauth grant required, but the token is refreshed in background")
+)
+
+const (
+	grantTypeRefreshToken = "refresh_token"
+	clientAssertionType   = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	clientAssertionTTL    = 5 * time.Minute
+	jwtIDBytes            = 16
+)
+
+// OAuthAuthMethod is the method the client uses to authenticate
+// at the token endpoint, see RFC 7591.
+type OAuthAuthMethod string
+
+const (
+	// OAuthClientSecretPost sends client_id and client_secret in the form body.
+	// This is the default.
+	OAuthClientSecretPost OAuthAuthMethod = "client_secret_post"
+
+	// OAuthClientSecretBasic sends client_id and client_secret with HTTP Basic auth.
+	OAuthClientSecretBasic OAuthAuthMethod = "client_secret_basic"
+
+	// OAuthPrivateKeyJWT sends a JWT client assertion signed with the private key.
+	OAuthPrivateKeyJWT OAuthAuthMethod = "private_key_jwt"
+)
 
 // OAuthConfig is the configuration for an OAuth token request.
 type OAuthConfig struct {
@@ -16,6 +50,90 @@ type OAuthConfig struct {
 	Scope        string
 	ClientID     string
 	ClientSecret string
+
+	// Scopes are additional scopes. They are joined with Scope,
+	// separated by spaces.
+	Scopes []string
+
+	// Audience is sent as audience parameter, required by Auth0 for example.
+	Audience string
+
+	// Resource is sent as resource parameter (RFC 8707), required by ADFS for example.
+	Resource string
+
+	// ExtraParams are added to the form body of all token requests.
+	ExtraParams url.Values
+
+	// AuthMethod is the client authentication method.
+	// It defaults to OAuthClientSecretPost.
+	AuthMethod OAuthAuthMethod
+
+	// PrivateKeyPEM is the PEM encoded RSA or EC P-256 private key
+	// used to sign the client assertion for OAuthPrivateKeyJWT.
+	PrivateKeyPEM []byte
+
+	// CertificatePEM is the optional PEM encoded certificate of the private key.
+	// Its SHA-1 thumbprint is sent as x5t header of the client assertion,
+	// which is required by Azure AD.
+	CertificatePEM []byte
+
+	// KeyID is the optional kid header of the client assertion.
+	KeyID string
+
+	// AssertionAudience is the audience of the client assertion.
+	// It defaults to URL.
+	AssertionAudience string
+
+	// RefreshToken is an initial refresh token, for example obtained
+	// by an authorization code flow. If set, the first token is requested
+	// with the refresh_token grant instead of GrantType.
+	RefreshToken string
+
+	// ExpiryMargin is subtracted from the token expiry,
+	// so the token is refreshed before it expires.
+	ExpiryMargin time.Duration
+
+	// ExpiryMarginRatio is the margin as fraction (0 to 1) of the token lifetime.
+	// If both ExpiryMargin and ExpiryMarginRatio are set, the larger margin is used.
+	// If none is set, the margin is 10% of the lifetime, but at most 5 minutes.
+	// Margins that are not shorter than the lifetime fall back to 10% of it.
+	ExpiryMarginRatio float64
+
+	// DeviceAuthorizationURL is the device authorization endpoint,
+	// required for GrantTypeDeviceCode.
+	DeviceAuthorizationURL string
+
+	// OnDeviceAuthorization is called with the user code and verification URI
+	// of the device code grant, so they can be shown to the user.
+	// It is required for GrantTypeDeviceCode.
+	OnDeviceAuthorization func(ctx context.Context, auth *DeviceAuthorization) error
+
+	// DevicePollInterval is the polling interval of the device code grant,
+	// if the server does not send one. It defaults to 5 seconds.
+	DevicePollInterval time.Duration
+
+	// DeviceSlowDown is added to the polling interval of the device code
+	// grant for each slow_down error. It defaults to 5 seconds.
+	DeviceSlowDown time.Duration
+
+	// AuthorizationURL is the authorization endpoint,
+	// required for GrantTypeAuthorizationCode.
+	AuthorizationURL string
+
+	// RedirectURL is the loopback redirect URL of the authorization code grant.
+	// It defaults to http://127.0.0.1/callback. Without port or with port 0,
+	// a free port is used.
+	RedirectURL string
+
+	// OnAuthorizationURL is called with the URL the user has to open
+	// in a browser to authorize the client, for example to open it.
+	// It is required for GrantTypeAuthorizationCode.
+	OnAuthorizationURL func(ctx context.Context, authURL string) error
+
+	// OnRefreshToken is called when the server issued a new refresh token,
+	// so it can be persisted. Errors are logged, but don't fail the
+	// token request.
+	OnRefreshToken func(ctx context.Context, refreshToken string) error
 }
 
 // OAuthToken is an OAuth token.
@@ -24,45 +142,294 @@ type OAuthToken struct {
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int    `json:"expires_in"`
 	ExtExpiresIn int    `json:"ext_expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 	// ValidUntil is the time when the token is no longer valid.
-	// It is calculated when a new token is received.
+	// It is calculated when a new token is received, from the exp claim
+	// of JWT access tokens or from expires_in, minus the expiry margin.
 	ValidUntil time.Time `json:"-"`
+
+	clock func() time.Time
 }
 
 type oauthTokenGetter struct {
 	config OAuthConfig
 	client *Client
+
+	signerOnce sync.Once
+	signer     *jwtSigner
+	signerErr  error
+
+	// refreshMu serializes refresh token grants and guards the latest
+	// refresh token, which is shared by all scopes.
+	refreshMu             sync.Mutex
+	latestRefreshToken    string
+	hasLatestRefreshToken bool
 }
 
 // GetToken is a TokenGetter implementation that requests a new OAuth token.
+// If a refresh token is available, the refresh_token grant is used first.
+// If that fails, it falls back to the configured grant type.
 // No synchronization or locking is required in this function.
 func (o *oauthTokenGetter) GetToken(ctx context.Context, oldToken Token) (Token, error) {
+	return o.GetScopedToken(ctx, TokenScope{}, oldToken)
+}
+
+// GetScopedToken is a ScopedTokenGetter implementation that requests a new
+// OAuth token for the given scope. The fields of the scope that are set
+// override Scope, Audience and Resource of the OAuth config.
+func (o *oauthTokenGetter) GetScopedToken(ctx context.Context, scope TokenScope, oldToken Token) (Token, error) {
+	token, ok, err := o.refreshTokenGrant(ctx, scope, oldToken)
+	if ok || err != nil {
+		return token, err
+	}
+
+	// nobody can log in while the token is refreshed in background
+	if o.interactive() && isBackgroundRefresh(ctx) {
+		return nil, ErrInteractiveGrantRequired
+	}
+
+	token, err = o.initialGrant(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// the previous refresh token is not kept, because it failed or didn't exist
+	o.handleRefreshToken(ctx, token, "")
+
+	o.refreshMu.Lock()
+	o.setLatestRefreshToken(token.RefreshToken)
+	o.refreshMu.Unlock()
+	return token, nil
+}
+
+// refreshTokenGrant requests a token with the latest refresh token. The refresh
+// token is shared by all scopes. Refresh token grants are serialized, because
+// servers that rotate refresh tokens revoke them if they are used twice.
+// It returns false and no error, if the initial grant should be used.
+func (o *oauthTokenGetter) refreshTokenGrant(ctx context.Context, scope TokenScope, oldToken Token) (*OAuthToken, bool, error) {
+	o.refreshMu.Lock()
+	defer o.refreshMu.Unlock()
+
+	refreshToken := o.latestRefreshToken
+	if !o.hasLatestRefreshToken {
+		refreshToken = o.config.RefreshToken
+		if old, ok := oldToken.(*OAuthToken); ok && old != nil && old.RefreshToken != "" {
+			refreshToken = old.RefreshToken
+		}
+	}
+	if refreshToken == "" {
+		return nil, false, nil
+	}
+
+	token, err := o.requestToken(ctx, o.refreshTokenGrantBody(refreshToken, scope))
+	if err == nil {
+		o.handleRefreshToken(ctx, token, refreshToken)
+		o.setLatestRefreshToken(token.RefreshToken)
+		return token, true, nil
+	}
+
+	if o.config.GrantType == "" || o.config.GrantType == grantTypeRefreshToken {
+		return nil, false, err
+	}
+
+	o.client.logger.LogAttrs(ctx, slog.LevelWarn,
+		"oauth refresh token grant failed, falling back to initial grant",
+		slog.String("grant_type", o.config.GrantType),
+		slog.String("error", err.Error()))
+	return nil, false, nil
+}
+
+// setLatestRefreshToken sets the refresh token used by the next refresh token grant.
+// It must be called with o.refreshMu locked.
+func (o *oauthTokenGetter) setLatestRefreshToken(refreshToken string) {
+	o.latestRefreshToken = refreshToken
+	o.hasLatestRefreshToken = true
+}
+
+// StoreToken returns the persisted form of an OAuth token,
+// including the refresh token.
+func (o *oauthTokenGetter) StoreToken(token Token) *StoredToken {
+	oauthToken, ok := token.(*OAuthToken)
+	if !ok || oauthToken.ValidUntil.IsZero() {
+		return nil
+	}
+	return &StoredToken{
+		AccessToken:  oauthToken.AccessToken,
+		RefreshToken: oauthToken.RefreshToken,
+		ValidUntil:   oauthToken.ValidUntil,
+	}
+}
+
+// RestoreToken returns the OAuth token of the persisted form.
+func (o *oauthTokenGetter) RestoreToken(stored *StoredToken) Token {
+	return &OAuthToken{
+		AccessToken:  stored.AccessToken,
+		RefreshToken: stored.RefreshToken,
+		ValidUntil:   stored.ValidUntil,
+		clock:        o.client.now,
+	}
+}
+
+// interactive reports whether the initial grant needs user interaction.
+func (o *oauthTokenGetter) interactive() bool {
+	return o.config.GrantType == GrantTypeDeviceCode || o.config.GrantType == GrantTypeAuthorizationCode
+}
+
+// initialGrant requests a token with the configured grant type.
+func (o *oauthTokenGetter) initialGrant(ctx context.Context, scope TokenScope) (*OAuthToken, error) {
+	switch o.config.GrantType {
+	case GrantTypeDeviceCode:
+		return o.deviceCodeGrant(ctx, scope)
+	case GrantTypeAuthorizationCode:
+		return o.authorizationCodeGrant(ctx, scope)
+	default:
+		return o.requestToken(ctx, o.initialGrantBody(scope))
+	}
+}
+
+func (o *oauthTokenGetter) initialGrantBody(scope TokenScope) url.Values {
+	body := o.newBody(scope)
+	body.Set("grant_type", o.config.GrantType)
+	return body
+}
+
+func (o *oauthTokenGetter) refreshTokenGrantBody(refreshToken string, scope TokenScope) url.Values {
+	body := o.newBody(scope)
+	body.Set("grant_type", grantTypeRefreshToken)
+	body.Set("refresh_token", refreshToken)
+	return body
+}
+
+// newBody returns a form body with the parameters common to all grants.
+func (o *oauthTokenGetter) newBody(scope TokenScope) url.Values {
 	body := url.Values{}
-	body.Add("grant_type", o.config.GrantType)
-	body.Add("scope", o.config.Scope)
-	body.Add("client_id", o.config.ClientID)
-	body.Add("client_secret", o.config.ClientSecret)
+	for key, values := range o.config.ExtraParams {
+		body[key] = slices.Clone(values)
+	}
+	setFormValue(body, "scope", cmp.Or(scope.Scope, o.config.scope()))
+	setFormValue(body, "audience", cmp.Or(scope.Audience, o.config.Audience))
+	setFormValue(body, "resource", cmp.Or(scope.Resource, o.config.Resource))
+	return body
+}
 
-	var token OAuthToken
-	err := o.client.NewRequestWithContext(ctx).
+func setFormValue(body url.Values, key, value string) {
+	if value != "" {
+		body.Set(key, value)
+	}
+}
+
+// scope returns Scope and Scopes joined by spaces.
+func (cfg *OAuthConfig) scope() string {
+	scopes := make([]string, 0, len(cfg.Scopes)+1)
+	for _, scope := range append([]string{cfg.Scope}, cfg.Scopes...) {
+		if scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+func (o *oauthTokenGetter) requestToken(ctx context.Context, body url.Values) (*OAuthToken, error) {
+	req := o.client.NewRequestWithContext(ctx).
 		SetBaseURL(o.config.URL).
-		SetContentType("application/x-www-form-urlencoded").
-		SetBody(body.Encode()).
-		SetTokenRequest().
+		SetTokenRequest()
+
+	if err := o.authenticateClient(req, body); err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+
+	var token OAuthToken
+	err := req.
+		SetFormData(body).
 		SetResponseBody(&token).
 		DoPost("")
 	if err != nil {
+		if oauthErr := parseOAuthError(err); oauthErr != nil {
+			return nil, errors.Join(ErrOAuthTokenRequestFailed, oauthErr)
+		}
 		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
 	}
 
-	safetyMarginMinutes := 5
-	token.ValidUntil = time.Now().
-		Add(time.Duration(token.ExpiresIn) * time.Second).
-		Add(time.Duration(safetyMarginMinutes) * time.Minute * -1)
-
+	o.setValidUntil(&token, req.Response.Raw)
 	return &token, nil
 }
 
+// authenticateClient adds the client authentication
+// to the token request according to the auth method.
+func (o *oauthTokenGetter) authenticateClient(req *Request, body url.Values) error {
+	switch o.config.AuthMethod {
+	case OAuthClientSecretPost, "":
+		body.Set("client_id", o.config.ClientID)
+		if o.config.ClientSecret != "" {
+			body.Set("client_secret", o.config.ClientSecret)
+		}
+	case OAuthClientSecretBasic:
+		// RFC 6749 requires the credentials to be form encoded
+		req.SetBasicAuth(url.QueryEscape(o.config.ClientID), url.QueryEscape(o.config.ClientSecret))
+	case OAuthPrivateKeyJWT:
+		assertion, err := o.clientAssertion()
+		if err != nil {
+			return err
+		}
+		body.Set("client_id", o.config.ClientID)
+		body.Set("client_assertion_type", clientAssertionType)
+		body.Set("client_assertion", assertion)
+	default:
+		return fmt.Errorf("unsupported oauth auth method %q", o.config.AuthMethod)
+	}
+	return nil
+}
+
+// clientAssertion returns a new signed JWT for private_key_jwt client authentication.
+func (o *oauthTokenGetter) clientAssertion() (string, error) {
+	o.signerOnce.Do(func() {
+		o.signer, o.signerErr = newJWTSigner(o.config.PrivateKeyPEM, o.config.CertificatePEM, o.config.KeyID)
+	})
+	if o.signerErr != nil {
+		return "", o.signerErr
+	}
+
+	audience := o.config.AssertionAudience
+	if audience == "" {
+		audience = o.config.URL
+	}
+
+	jwtID := make([]byte, jwtIDBytes)
+	if _, err := rand.Read(jwtID); err != nil {
+		return "", err
+	}
+
+	now := o.client.now()
+	return o.signer.sign(map[string]any{
+		"iss": o.config.ClientID,
+		"sub": o.config.ClientID,
+		"aud": audience,
+		"jti": hex.EncodeToString(jwtID),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(clientAssertionTTL).Unix(),
+	})
+}
+
+// handleRefreshToken keeps the previous refresh token, if the server did not
+// rotate it, and calls the OnRefreshToken hook for new refresh tokens.
+func (o *oauthTokenGetter) handleRefreshToken(ctx context.Context, token *OAuthToken, previous string) {
+	if token.RefreshToken == "" {
+		token.RefreshToken = previous
+		return
+	}
+
+	if token.RefreshToken == previous || o.config.OnRefreshToken == nil {
+		return
+	}
+
+	if err := o.config.OnRefreshToken(ctx, token.RefreshToken); err != nil {
+		o.client.logger.LogAttrs(ctx, slog.LevelError,
+			"error when persisting oauth refresh token",
+			slog.String("error", err.Error()))
+	}
+}
+
 // Token returns the actual token.
 func (t *OAuthToken) Token() string {
 	if t == nil {
@@ -72,12 +439,25 @@ func (t *OAuthToken) Token() string {
 	return t.AccessToken
 }
 
+// ExpiresAt returns the time when the token is no longer valid.
+func (t *OAuthToken) ExpiresAt() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return t.ValidUntil
+}
+
 // NeedsRefresh returns true if the token needs to be refreshed.
-// There is a safety margin of 5 minutes.
+// ValidUntil already contains the safety margin.
 func (t *OAuthToken) NeedsRefresh() bool {
 	if t == nil || t.AccessToken == "" {
 		return true
 	}
 
-	return time.Now().After(t.ValidUntil)
+	now := time.Now
+	if t.clock != nil {
+		now = t.clock
+	}
+	return now().After(t.ValidUntil)
 }
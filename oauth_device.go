@@ -0,0 +1,113 @@
+package vrest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GrantTypeDeviceCode is the grant type of the OAuth device authorization grant (RFC 8628).
+const GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	defaultDevicePollInterval = 5 * time.Second
+
+	// defaultDeviceSlowDown is added to the polling interval
+	// for each slow_down error, as required by RFC 8628.
+	defaultDeviceSlowDown = 5 * time.Second
+)
+
+var ErrOAuthDeviceCodeExpired = errors.New("oauth device code expired before the user authorized the device")
+
+// DeviceAuthorization is the response of the device authorization endpoint.
+// The user has to open VerificationURI and enter UserCode.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// deviceCodeGrant runs the device authorization grant. It requests a device code,
+// passes it to OnDeviceAuthorization and polls the token endpoint until the
+// user authorized the device, denied the request or the device code expired.
+func (o *oauthTokenGetter) deviceCodeGrant(ctx context.Context, scope TokenScope) (*OAuthToken, error) {
+	if o.config.OnDeviceAuthorization == nil {
+		return nil, fmt.Errorf("%w: OnDeviceAuthorization is required for the device code grant", ErrOAuthTokenRequestFailed)
+	}
+
+	auth, err := o.requestDeviceAuthorization(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = o.config.OnDeviceAuthorization(ctx, auth); err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+
+	if auth.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, time.Duration(auth.ExpiresIn)*time.Second, ErrOAuthDeviceCodeExpired)
+		defer cancel()
+	}
+
+	interval := cmp.Or(time.Duration(auth.Interval)*time.Second, o.config.DevicePollInterval, defaultDevicePollInterval)
+	for {
+		if err = sleepContext(ctx, interval); err != nil {
+			return nil, errors.Join(ErrOAuthTokenRequestFailed, context.Cause(ctx))
+		}
+
+		body := o.newBody(scope)
+		body.Set("grant_type", GrantTypeDeviceCode)
+		body.Set("device_code", auth.DeviceCode)
+
+		token, err := o.requestToken(ctx, body)
+		var oauthErr *OAuthError
+		if err == nil || !errors.As(err, &oauthErr) {
+			return token, err
+		}
+
+		switch oauthErr.ErrorCode {
+		case "authorization_pending":
+		case "slow_down":
+			interval += cmp.Or(o.config.DeviceSlowDown, defaultDeviceSlowDown)
+		case "expired_token":
+			return nil, errors.Join(ErrOAuthDeviceCodeExpired, err)
+		default:
+			return nil, err
+		}
+	}
+}
+
+// requestDeviceAuthorization requests a device code and user code.
+func (o *oauthTokenGetter) requestDeviceAuthorization(ctx context.Context, scope TokenScope) (*DeviceAuthorization, error) {
+	req := o.client.NewRequestWithContext(ctx).
+		SetBaseURL(o.config.DeviceAuthorizationURL).
+		SetTokenRequest()
+
+	body := o.newBody(scope)
+	if err := o.authenticateClient(req, body); err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+
+	var auth DeviceAuthorization
+	err := req.
+		SetFormData(body).
+		SetResponseBody(&auth).
+		DoPost("")
+	if err != nil {
+		if oauthErr := parseOAuthError(err); oauthErr != nil {
+			return nil, errors.Join(ErrOAuthTokenRequestFailed, oauthErr)
+		}
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+	if auth.DeviceCode == "" {
+		return nil, fmt.Errorf("%w: device authorization response contains no device code", ErrOAuthTokenRequestFailed)
+	}
+
+	return &auth, nil
+}
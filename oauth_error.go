@@ -0,0 +1,57 @@
+package vrest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// OAuthError is an RFC 6749 error response of an OAuth token endpoint.
+// It is returned joined with ErrOAuthTokenRequestFailed and wraps the
+// HTTPError of the token request.
+type OAuthError struct {
+	// ErrorCode is the error code, for example "invalid_client".
+	ErrorCode        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorURI         string `json:"error_uri"`
+
+	// Err is the HTTPError of the token request.
+	Err error `json:"-"`
+}
+
+// Error returns the error code, description and URI.
+func (e *OAuthError) Error() string {
+	msg := "oauth error " + e.ErrorCode
+	if e.ErrorDescription != "" {
+		msg += ": " + e.ErrorDescription
+	}
+	if e.ErrorURI != "" {
+		msg += fmt.Sprintf(" (see %s)", e.ErrorURI)
+	}
+	if statusCode := StatusCodeOf(e.Err); statusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", statusCode)
+	}
+	return msg
+}
+
+// Unwrap returns the HTTPError of the token request.
+func (e *OAuthError) Unwrap() error {
+	return e.Err
+}
+
+// parseOAuthError returns the OAuthError from the body of the HTTPError
+// in err, or nil if the body is not an OAuth error response.
+func parseOAuthError(err error) *OAuthError {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || len(httpErr.Body) == 0 {
+		return nil
+	}
+
+	var oauthErr OAuthError
+	if json.Unmarshal(httpErr.Body, &oauthErr) != nil || oauthErr.ErrorCode == "" {
+		return nil
+	}
+
+	oauthErr.Err = err
+	return &oauthErr
+}
@@ -0,0 +1,67 @@
+package vrest
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	defaultExpiryMargin      = 5 * time.Minute
+	defaultExpiryMarginRatio = 0.1
+)
+
+// setValidUntil calculates ValidUntil of a new token. The expiry is taken
+// from the exp claim if the access token is a JWT, otherwise from expires_in.
+// The exp claim is converted to the local clock with the Date header of the response.
+func (o *oauthTokenGetter) setValidUntil(token *OAuthToken, resp *http.Response) {
+	now := o.client.now()
+	token.clock = o.client.now
+
+	expiresAt := now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	if exp, ok := jwtExpiry(token.AccessToken); ok {
+		expiresAt = exp.Add(-serverClockSkew(resp, now))
+	}
+
+	token.ValidUntil = validUntil(now, expiresAt, o.config.ExpiryMargin, o.config.ExpiryMarginRatio)
+}
+
+// validUntil returns the expiry minus the safety margin,
+// see OAuthConfig.ExpiryMargin and OAuthConfig.ExpiryMarginRatio.
+func validUntil(now, expiresAt time.Time, absolute time.Duration, ratio float64) time.Time {
+	lifetime := max(expiresAt.Sub(now), 0)
+	return expiresAt.Add(-expiryMargin(lifetime, absolute, ratio))
+}
+
+// expiryMargin returns the safety margin for a token with the given lifetime.
+func expiryMargin(lifetime, absolute time.Duration, ratio float64) time.Duration {
+	defaultMargin := time.Duration(float64(lifetime) * defaultExpiryMarginRatio)
+	if absolute <= 0 && ratio <= 0 {
+		return min(defaultMargin, defaultExpiryMargin)
+	}
+
+	margin := max(absolute, time.Duration(float64(lifetime)*ratio))
+	if margin >= lifetime {
+		return defaultMargin
+	}
+	return margin
+}
+
+// serverClockSkew returns how far the server clock is ahead of the local clock,
+// based on the Date header of the response. The header has a precision of one
+// second, so smaller differences are ignored.
+func serverClockSkew(resp *http.Response, now time.Time) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+
+	skew := date.Sub(now)
+	if skew.Abs() <= time.Second {
+		return 0
+	}
+	return skew
+}
@@ -0,0 +1,196 @@
+package vrest
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// GrantTypeAuthorizationCode is the grant type of the OAuth authorization code grant.
+// vrest always uses it with PKCE (RFC 7636) and a loopback redirect (RFC 8252).
+const GrantTypeAuthorizationCode = "authorization_code"
+
+const (
+	defaultRedirectURL      = "http://127.0.0.1/callback"
+	pkceVerifierBytes       = 32
+	oauthStateBytes         = 16
+	callbackShutdownTimeout = time.Second
+	callbackHeaderTimeout   = 10 * time.Second
+)
+
+var ErrOAuthStateMismatch = errors.New("oauth callback state does not match")
+
+// authorizationResult is the result of the loopback callback.
+type authorizationResult struct {
+	code string
+	err  error
+}
+
+// authorizationCodeGrant runs the authorization code grant with PKCE. It starts
+// a loopback listener, passes the authorize URL to OnAuthorizationURL, waits
+// for the redirect with the code and exchanges the code for a token.
+// The returned token contains the refresh token, if the server issued one.
+func (o *oauthTokenGetter) authorizationCodeGrant(ctx context.Context, scope TokenScope) (*OAuthToken, error) {
+	if o.config.OnAuthorizationURL == nil {
+		return nil, fmt.Errorf("%w: OnAuthorizationURL is required for the authorization code grant", ErrOAuthTokenRequestFailed)
+	}
+
+	redirectURL, err := url.Parse(cmp.Or(o.config.RedirectURL, defaultRedirectURL))
+	if err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+
+	// without port, the system chooses a free port
+	listenAddr := net.JoinHostPort(redirectURL.Hostname(), cmp.Or(redirectURL.Port(), "0"))
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", listenAddr)
+	if err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		_ = listener.Close()
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+	redirectURL.Host = net.JoinHostPort(redirectURL.Hostname(), port)
+
+	verifier, err := randomString(pkceVerifierBytes)
+	if err != nil {
+		_ = listener.Close()
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+	state, err := randomString(oauthStateBytes)
+	if err != nil {
+		_ = listener.Close()
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+
+	results := make(chan authorizationResult, 1)
+	server := &http.Server{
+		Handler:           callbackHandler(redirectURL.Path, state, results),
+		ReadHeaderTimeout: callbackHeaderTimeout,
+	}
+	// browsers may keep idle or preconnected connections open,
+	// they must not delay the shutdown after the callback
+	server.SetKeepAlivesEnabled(false)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), callbackShutdownTimeout)
+		defer cancel()
+		if server.Shutdown(shutdownCtx) != nil {
+			_ = server.Close()
+		}
+	}()
+
+	authURL, err := o.authorizationURL(scope, redirectURL.String(), state, verifier)
+	if err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+	if err = o.config.OnAuthorizationURL(ctx, authURL); err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, err)
+	}
+
+	var result authorizationResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, ctx.Err())
+	}
+	if result.err != nil {
+		return nil, errors.Join(ErrOAuthTokenRequestFailed, result.err)
+	}
+
+	body := o.newBody(scope)
+	body.Set("grant_type", GrantTypeAuthorizationCode)
+	body.Set("code", result.code)
+	body.Set("redirect_uri", redirectURL.String())
+	body.Set("code_verifier", verifier)
+	return o.requestToken(ctx, body)
+}
+
+// authorizationURL returns the URL the user has to open to authorize the client.
+func (o *oauthTokenGetter) authorizationURL(scope TokenScope, redirectURL, state, verifier string) (string, error) {
+	authURL, err := url.Parse(o.config.AuthorizationURL)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := authURL.Query()
+	for key, values := range o.newBody(scope) {
+		query[key] = values
+	}
+	query.Set("response_type", "code")
+	query.Set("client_id", o.config.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// callbackHandler handles the redirect of the authorization server.
+// Only the first callback with a matching path and state is used.
+// Requests with a wrong state are rejected, so stray requests
+// can't abort the flow.
+func callbackHandler(path, state string, results chan<- authorizationResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != cmp.Or(path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, ErrOAuthStateMismatch.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var result authorizationResult
+		switch {
+		case query.Get("error") != "":
+			result.err = &OAuthError{
+				ErrorCode:        query.Get("error"),
+				ErrorDescription: query.Get("error_description"),
+				ErrorURI:         query.Get("error_uri"),
+			}
+		case query.Get("code") == "":
+			result.err = errors.New("oauth callback contains no code")
+		default:
+			result.code = query.Get("code")
+		}
+
+		select {
+		case results <- result:
+		default:
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if result.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Authorization failed: %v\n", result.err)
+			return
+		}
+		_, _ = w.Write([]byte("Authorization succeeded. You can close this window.\n"))
+	})
+}
+
+// randomString returns n random bytes, base64url encoded without padding.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
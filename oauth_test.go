@@ -0,0 +1,542 @@
+package vrest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestOAuthServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("grant_type") {
+		case "client_credentials":
+			_, _ = w.Write([]byte(`{"access_token":"initial","expires_in":3600,"refresh_token":"rt-1"}`))
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "rt-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"refreshed","expires_in":3600,"refresh_token":"rt-2"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestOAuthTokenGetter_refreshToken(t *testing.T) {
+	ts := newTestOAuthServer(t)
+	defer ts.Close()
+
+	var persisted []string
+	getter := &oauthTokenGetter{
+		client: NewWithClient(ts.Client()),
+		config: OAuthConfig{
+			URL:       ts.URL,
+			GrantType: "client_credentials",
+			OnRefreshToken: func(_ context.Context, refreshToken string) error {
+				persisted = append(persisted, refreshToken)
+				return nil
+			},
+		},
+	}
+
+	ctx := context.Background()
+	wantTokens := []string{"initial", "refreshed", "initial"}
+	var token Token
+	for i, want := range wantTokens {
+		var err error
+		token, err = getter.GetToken(ctx, token)
+		if err != nil {
+			t.Fatalf("unexpected error in step %d: %v", i, err)
+		}
+		if token.Token() != want {
+			t.Fatalf("unexpected token in step %d: got %s, want %s", i, token.Token(), want)
+		}
+	}
+
+	// rt-2 is rejected by the server, so the getter falls back to client credentials
+	if len(persisted) != 3 || persisted[1] != "rt-2" {
+		t.Fatalf("unexpected persisted refresh tokens: %v", persisted)
+	}
+}
+
+func TestOAuthTokenGetter_authMethods(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config OAuthConfig
+		verify func(t *testing.T, r *http.Request)
+	}{{
+		name:   "client secret basic",
+		config: OAuthConfig{AuthMethod: OAuthClientSecretBasic, ClientID: "my client", ClientSecret: "s3cr:t"},
+		verify: func(t *testing.T, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok || user != "my+client" || password != "s3cr%3At" || r.PostForm.Has("client_secret") {
+				t.Errorf("unexpected client authentication: %s %s", user, password)
+			}
+		},
+	}, {
+		name: "private key jwt rsa",
+		config: OAuthConfig{
+			AuthMethod:    OAuthPrivateKeyJWT,
+			ClientID:      "client",
+			PrivateKeyPEM: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		},
+		verify: func(t *testing.T, r *http.Request) {
+			signingInput, signature := splitTestJWT(t, r.PostForm.Get("client_assertion"))
+			digest := sha256.Sum256([]byte(signingInput))
+			if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				t.Errorf("invalid signature: %v", err)
+			}
+		},
+	}, {
+		name: "private key jwt ec",
+		config: OAuthConfig{
+			AuthMethod:    OAuthPrivateKeyJWT,
+			ClientID:      "client",
+			PrivateKeyPEM: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+		},
+		verify: func(t *testing.T, r *http.Request) {
+			signingInput, signature := splitTestJWT(t, r.PostForm.Get("client_assertion"))
+			digest := sha256.Sum256([]byte(signingInput))
+			r1 := new(big.Int).SetBytes(signature[:32])
+			s1 := new(big.Int).SetBytes(signature[32:])
+			if !ecdsa.Verify(&ecKey.PublicKey, digest[:], r1, s1) {
+				t.Error("invalid signature")
+			}
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Errorf("failed to parse form: %v", err)
+				}
+				tt.verify(t, r)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+			}))
+			defer ts.Close()
+
+			tt.config.URL = ts.URL
+			tt.config.GrantType = "client_credentials"
+			getter := &oauthTokenGetter{client: NewWithClient(ts.Client()), config: tt.config}
+			if _, err := getter.GetToken(context.Background(), nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func splitTestJWT(t *testing.T, jwt string) (string, []byte) {
+	t.Helper()
+
+	i := strings.LastIndex(jwt, ".")
+	if i < 0 {
+		t.Fatalf("invalid jwt %q", jwt)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(jwt[i+1:])
+	if err != nil {
+		t.Fatalf("invalid jwt signature: %v", err)
+	}
+	return jwt[:i], signature
+}
+
+func TestOAuthTokenGetter_errorAndParams(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		form := r.PostForm
+		if form.Get("scope") != "read write" || form.Get("audience") != "api" ||
+			form.Get("resource") != "urn:erp" || form.Get("tenant") != "42" {
+			t.Errorf("unexpected form: %v", form)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+	}))
+	defer ts.Close()
+
+	getter := &oauthTokenGetter{
+		client: NewWithClient(ts.Client()),
+		config: OAuthConfig{
+			URL:         ts.URL,
+			GrantType:   "client_credentials",
+			Scope:       "read",
+			Scopes:      []string{"write"},
+			Audience:    "api",
+			Resource:    "urn:erp",
+			ExtraParams: url.Values{"tenant": {"42"}},
+		},
+	}
+
+	_, err := getter.GetToken(context.Background(), nil)
+	if !errors.Is(err, ErrOAuthTokenRequestFailed) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.ErrorCode != "invalid_client" || oauthErr.ErrorDescription != "unknown client" {
+		t.Fatalf("unexpected oauth error: %v", err)
+	}
+	if !IsUnauthorized(err) {
+		t.Fatalf("http error is not wrapped: %v", err)
+	}
+}
+
+func TestOAuthTokenGetter_expiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	exp := now.Add(time.Hour).Unix()
+	jwt := "eyJhbGciOiJub25lIn0." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"exp":`+strconv.FormatInt(exp, 10)+`}`)) + ".sig"
+
+	tests := []struct {
+		name           string
+		response       string
+		serverDate     time.Time
+		config         OAuthConfig
+		wantValidUntil time.Time
+	}{
+		{
+			name:           "default margin is capped at 5 minutes",
+			response:       `{"access_token":"token","expires_in":3600}`,
+			wantValidUntil: now.Add(55 * time.Minute),
+		},
+		{
+			name:           "default margin of short lived tokens",
+			response:       `{"access_token":"token","expires_in":60}`,
+			wantValidUntil: now.Add(54 * time.Second),
+		},
+		{
+			name:           "absolute margin",
+			response:       `{"access_token":"token","expires_in":3600}`,
+			config:         OAuthConfig{ExpiryMargin: 10 * time.Minute},
+			wantValidUntil: now.Add(50 * time.Minute),
+		},
+		{
+			name:           "proportional margin",
+			response:       `{"access_token":"token","expires_in":3600}`,
+			config:         OAuthConfig{ExpiryMarginRatio: 0.5},
+			wantValidUntil: now.Add(30 * time.Minute),
+		},
+		{
+			name:           "margin longer than lifetime",
+			response:       `{"access_token":"token","expires_in":60}`,
+			config:         OAuthConfig{ExpiryMargin: 5 * time.Minute},
+			wantValidUntil: now.Add(54 * time.Second),
+		},
+		{
+			name:           "jwt exp claim",
+			response:       `{"access_token":"` + jwt + `","expires_in":60}`,
+			serverDate:     now,
+			wantValidUntil: now.Add(55 * time.Minute),
+		},
+		{
+			name:           "jwt exp claim with server clock ahead",
+			response:       `{"access_token":"` + jwt + `","expires_in":60}`,
+			serverDate:     now.Add(10 * time.Minute),
+			wantValidUntil: now.Add(45 * time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if !tt.serverDate.IsZero() {
+					w.Header().Set("Date", tt.serverDate.Format(http.TimeFormat))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer ts.Close()
+
+			clock := now
+			tt.config.URL = ts.URL
+			tt.config.GrantType = "client_credentials"
+			getter := &oauthTokenGetter{
+				config: tt.config,
+				client: NewWithClient(ts.Client()).SetClock(func() time.Time { return clock }),
+			}
+
+			token, err := getter.GetToken(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			oauthToken := token.(*OAuthToken)
+			if !oauthToken.ValidUntil.Equal(tt.wantValidUntil) {
+				t.Fatalf("unexpected ValidUntil: got %v, want %v", oauthToken.ValidUntil, tt.wantValidUntil)
+			}
+			if token.NeedsRefresh() {
+				t.Fatal("new token must not need refresh")
+			}
+
+			clock = tt.wantValidUntil.Add(time.Second)
+			if !token.NeedsRefresh() {
+				t.Fatal("token must need refresh after ValidUntil")
+			}
+		})
+	}
+}
+
+func TestOAuthTokenGetter_deviceCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []string
+		wantErr   error
+	}{
+		{
+			name:      "authorized",
+			responses: []string{"authorization_pending", "slow_down", ""},
+		},
+		{
+			name:      "denied",
+			responses: []string{"authorization_pending", "access_denied"},
+			wantErr:   ErrOAuthTokenRequestFailed,
+		},
+		{
+			name:      "expired",
+			responses: []string{"expired_token"},
+			wantErr:   ErrOAuthDeviceCodeExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Errorf("failed to parse form: %v", err)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == "/device" {
+					_, _ = w.Write([]byte(`{"device_code":"device","user_code":"ABCD-EFGH",` +
+						`"verification_uri":"https://example.com/device","expires_in":60}`))
+					return
+				}
+
+				if r.PostForm.Get("grant_type") != GrantTypeDeviceCode || r.PostForm.Get("device_code") != "device" {
+					t.Errorf("unexpected token request: %v", r.PostForm)
+				}
+				response := tt.responses[min(polls, len(tt.responses)-1)]
+				polls++
+				if response != "" {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(`{"error":"` + response + `"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+			}))
+			defer ts.Close()
+
+			var userCode string
+			getter := &oauthTokenGetter{
+				config: OAuthConfig{
+					URL:                    ts.URL + "/token",
+					DeviceAuthorizationURL: ts.URL + "/device",
+					GrantType:              GrantTypeDeviceCode,
+					ClientID:               "cli",
+					DevicePollInterval:     time.Millisecond,
+					DeviceSlowDown:         time.Millisecond,
+					OnDeviceAuthorization: func(_ context.Context, auth *DeviceAuthorization) error {
+						userCode = auth.UserCode
+						return nil
+					},
+				},
+				client: NewWithClient(ts.Client()),
+			}
+
+			token, err := getter.GetToken(context.Background(), nil)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if userCode != "ABCD-EFGH" {
+				t.Fatalf("unexpected user code: %q", userCode)
+			}
+			if polls != len(tt.responses) {
+				t.Fatalf("unexpected number of polls: %d", polls)
+			}
+			if err == nil && token.Token() != "token" {
+				t.Fatalf("unexpected token: %s", token.Token())
+			}
+		})
+	}
+}
+
+func TestOAuthTokenGetter_authorizationCode(t *testing.T) {
+	var challenge string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("grant_type") != GrantTypeAuthorizationCode ||
+			r.PostForm.Get("code") != "code" ||
+			!strings.HasPrefix(r.PostForm.Get("redirect_uri"), "http://localhost:") ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600,"refresh_token":"rt"}`))
+	}))
+	defer ts.Close()
+
+	// the browser of the user
+	callback := func(ctx context.Context, redirectURI, state string) int {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			redirectURI+"?code=code&state="+url.QueryEscape(state), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	getter := &oauthTokenGetter{
+		config: OAuthConfig{
+			URL:              ts.URL,
+			AuthorizationURL: "https://example.com/authorize",
+			RedirectURL:      "http://localhost/callback",
+			GrantType:        GrantTypeAuthorizationCode,
+			ClientID:         "desktop",
+			Scope:            "openid offline_access",
+			OnAuthorizationURL: func(ctx context.Context, authURL string) error {
+				u, err := url.Parse(authURL)
+				if err != nil {
+					return err
+				}
+				query := u.Query()
+				if query.Get("code_challenge_method") != "S256" || query.Get("scope") != "openid offline_access" {
+					t.Errorf("unexpected authorize URL: %s", authURL)
+				}
+				challenge = query.Get("code_challenge")
+
+				// a stray request with a wrong state is rejected, the flow continues
+				if status := callback(ctx, query.Get("redirect_uri"), "forged"); status != http.StatusBadRequest {
+					t.Errorf("unexpected status for wrong state: %d", status)
+				}
+				if status := callback(ctx, query.Get("redirect_uri"), query.Get("state")); status != http.StatusOK {
+					t.Errorf("unexpected status for callback: %d", status)
+				}
+				return nil
+			},
+		},
+		client: NewWithClient(ts.Client()),
+	}
+
+	token, err := getter.GetToken(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	oauthToken := token.(*OAuthToken)
+	if oauthToken.AccessToken != "token" || oauthToken.RefreshToken != "rt" {
+		t.Fatalf("unexpected token: %+v", oauthToken)
+	}
+}
+
+func TestOAuthTokenGetter_sharedRefreshToken(t *testing.T) {
+	var mu sync.Mutex
+	valid := "rt-1"
+	rotations := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if r.PostForm.Get("refresh_token") != valid {
+			// reuse of a rotated refresh token
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		rotations++
+		valid = "rt-" + strconv.Itoa(rotations+1)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600,"refresh_token":"` + valid + `"}`))
+	}))
+	defer ts.Close()
+
+	c := NewWithClient(ts.Client()).SetOAuth(OAuthConfig{
+		URL:          ts.URL,
+		GrantType:    grantTypeRefreshToken,
+		RefreshToken: "rt-1",
+	})
+
+	for _, scope := range []TokenScope{{Scope: "orders"}, {Scope: "stock"}, {Scope: "prices"}} {
+		if _, err := c.getValidToken(context.Background(), scope); err != nil {
+			t.Fatalf("unexpected error for scope %v: %v", scope, err)
+		}
+	}
+	if rotations != 3 {
+		t.Fatalf("unexpected number of rotations: %d", rotations)
+	}
+}
+
+func TestOAuthTokenGetter_interactiveInBackground(t *testing.T) {
+	getter := &oauthTokenGetter{
+		config: OAuthConfig{
+			GrantType: GrantTypeDeviceCode,
+			OnDeviceAuthorization: func(context.Context, *DeviceAuthorization) error {
+				t.Error("device flow must not be started in background")
+				return nil
+			},
+		},
+		client: New(),
+	}
+
+	ctx := context.WithValue(context.Background(), backgroundRefreshKey{}, true)
+	if _, err := getter.GetToken(ctx, nil); !errors.Is(err, ErrInteractiveGrantRequired) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
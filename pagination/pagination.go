@@ -0,0 +1,123 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/fond-of-vertigo/vrest"
+)
+
+// DefaultMaxPages is the default maximum number of pages that are
+// requested, to prevent endless loops with misbehaving servers.
+const DefaultMaxPages = 1000
+
+var ErrMaxPagesExceeded = errors.New("maximum number of pages exceeded")
+
+// Page is a fetched page. It is passed to the strategy
+// to prepare the request for the next page.
+type Page[P any] struct {
+	// Number is the number of the page, starting at 1.
+	Number int
+
+	// Request is the request that fetched the page.
+	// It gives access to the response headers.
+	Request *vrest.Request
+
+	// Body is the unmarshaled response body of the page.
+	Body P
+
+	// ItemCount is the number of items the page contained.
+	ItemCount int
+}
+
+// Strategy decides how the request for the next page is built.
+type Strategy[P any] interface {
+	// Next prepares req to fetch the page following prev.
+	// For the first page, prev is nil.
+	// It returns false if there are no more pages.
+	Next(req *vrest.Request, prev *Page[P]) bool
+}
+
+// StrategyFunc is a function that implements Strategy.
+type StrategyFunc[P any] func(req *vrest.Request, prev *Page[P]) bool
+
+// Next calls f(req, prev).
+func (f StrategyFunc[P]) Next(req *vrest.Request, prev *Page[P]) bool {
+	return f(req, prev)
+}
+
+type config struct {
+	maxPages int
+}
+
+// Option configures the pagination.
+type Option func(cfg *config)
+
+// WithMaxPages sets the maximum number of pages that are requested.
+// If there are more pages, the iteration stops with ErrMaxPagesExceeded.
+// A value of 0 disables the limit.
+func WithMaxPages(maxPages int) Option {
+	return func(cfg *config) {
+		cfg.maxPages = maxPages
+	}
+}
+
+// Items returns an iterator over all items of all pages.
+// newRequest is called for each page and must return a new request,
+// for example client.NewRequest, so all pages use the auth, tracing
+// and error handling of the client. Each page is requested with GET
+// at path, modified by the strategy, and unmarshaled into P.
+// items extracts the items from a page.
+//
+// If a request fails, the error is yielded and the iteration stops.
+func Items[P, T any](
+	newRequest func() *vrest.Request,
+	path string,
+	strategy Strategy[P],
+	items func(page P) []T,
+	opts ...Option,
+) iter.Seq2[T, error] {
+	cfg := config{maxPages: DefaultMaxPages}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(yield func(T, error) bool) {
+		var zero T
+		var prev *Page[P]
+
+		for number := 1; ; number++ {
+			req := newRequest()
+			req.Path = path
+			if !strategy.Next(req, prev) {
+				return
+			}
+
+			if cfg.maxPages > 0 && number > cfg.maxPages {
+				yield(zero, fmt.Errorf("%w: %d", ErrMaxPagesExceeded, cfg.maxPages))
+				return
+			}
+
+			var body P
+			if err := req.SetResponseBody(&body).DoGet(req.Path); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			pageItems := items(body)
+			for _, item := range pageItems {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			prev = &Page[P]{
+				Number:    number,
+				Request:   req,
+				Body:      body,
+				ItemCount: len(pageItems),
+			}
+		}
+	}
+}
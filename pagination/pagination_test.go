@@ -0,0 +1,162 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fond-of-vertigo/vrest"
+)
+
+type testPage struct {
+	Items      []int  `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /link", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		// the next link must be requested verbatim
+		if page > 0 && r.URL.RawQuery != fmt.Sprintf("token=a%%2Fb&page=%d", page) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`</link?token=a%%2Fb&page=%d>; rel="next", </link?page=2>; rel="last"`, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"items":[%d,%d]}`, page*2, page*2+1)
+	})
+	mux.HandleFunc("GET /cursor", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			_, _ = w.Write([]byte(`{"items":[1,2],"next_cursor":"abc"}`))
+		case "abc":
+			_, _ = w.Write([]byte(`{"items":[3]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	// five items, served by page number or offset
+	writeItems := func(w http.ResponseWriter, offset, limit int) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"items":%s}`, itemsJSON(offset, limit))
+	}
+	mux.HandleFunc("GET /pages", func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeItems(w, page*2, 2)
+	})
+	mux.HandleFunc("GET /offset", func(w http.ResponseWriter, r *http.Request) {
+		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeItems(w, offset, limit)
+	})
+	return httptest.NewServer(mux)
+}
+
+// itemsJSON returns the JSON array of the items 0 to 4 in the range.
+func itemsJSON(offset, limit int) string {
+	items := []string{}
+	for i := offset; i < min(offset+limit, 5); i++ {
+		items = append(items, strconv.Itoa(i))
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+func TestItems(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+
+	client := vrest.NewWithClient(ts.Client()).SetBaseURL(ts.URL)
+	items := func(p testPage) []int { return p.Items }
+
+	tests := []struct {
+		name     string
+		path     string
+		strategy Strategy[testPage]
+		opts     []Option
+		want     []int
+		wantErr  error
+	}{{
+		name:     "link header",
+		path:     "/link",
+		strategy: LinkHeader[testPage](),
+		want:     []int{0, 1, 2, 3, 4, 5},
+	}, {
+		name:     "link header max pages",
+		path:     "/link",
+		strategy: LinkHeader[testPage](),
+		opts:     []Option{WithMaxPages(2)},
+		want:     []int{0, 1, 2, 3},
+		wantErr:  ErrMaxPagesExceeded,
+	}, {
+		name:     "cursor",
+		path:     "/cursor",
+		strategy: Cursor("cursor", func(p testPage) string { return p.NextCursor }),
+		want:     []int{1, 2, 3},
+	}, {
+		name:     "page number",
+		path:     "/pages",
+		strategy: PageNumber[testPage]("page", 0),
+		want:     []int{0, 1, 2, 3, 4},
+	}, {
+		name:     "page number max pages",
+		path:     "/pages",
+		strategy: PageNumber[testPage]("page", 1),
+		opts:     []Option{WithMaxPages(2)},
+		want:     []int{2, 3, 4},
+		wantErr:  ErrMaxPagesExceeded,
+	}, {
+		name:     "offset",
+		path:     "/offset",
+		strategy: Offset[testPage]("offset", "limit", 2),
+		want:     []int{0, 1, 2, 3, 4},
+	}, {
+		name:     "offset with full last page",
+		path:     "/offset",
+		strategy: Offset[testPage]("offset", "limit", 5),
+		want:     []int{0, 1, 2, 3, 4},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			var gotErr error
+			for item, err := range Items(client.NewRequest, tt.path, tt.strategy, items, tt.opts...) {
+				if err != nil {
+					gotErr = err
+					break
+				}
+				got = append(got, item)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unexpected items:\ngot:  %v\nwant: %v", got, tt.want)
+			}
+			if tt.wantErr == nil && gotErr != nil {
+				t.Fatalf("unexpected error: %v", gotErr)
+			}
+			if tt.wantErr != nil && !errors.Is(gotErr, tt.wantErr) {
+				t.Fatalf("unexpected error:\ngot:  %v\nwant: %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package pagination
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fond-of-vertigo/vrest"
+)
+
+// LinkHeader follows the RFC 8288 Link header with rel="next".
+// Relative links are resolved against the URL of the previous page.
+// The next link is requested verbatim, including its query.
+// The pagination stops if there is no next link.
+func LinkHeader[P any]() Strategy[P] {
+	return StrategyFunc[P](func(req *vrest.Request, prev *Page[P]) bool {
+		if prev == nil {
+			return true
+		}
+
+		next, ok := nextLink(prev.Request.Response.Header().Values("Link"))
+		if !ok {
+			return false
+		}
+
+		nextURL, err := prev.Request.Raw.URL.Parse(next)
+		if err != nil {
+			return false
+		}
+
+		// the next URL is requested as it is, without
+		// encoding the query parameters again
+		req.Path = nextURL.String()
+		req.Query = url.Values{}
+		return true
+	})
+}
+
+// Cursor sets the query parameter param to the cursor returned by cursor
+// for the previous page. The pagination stops if the cursor is empty.
+func Cursor[P any](param string, cursor func(page P) string) Strategy[P] {
+	return StrategyFunc[P](func(req *vrest.Request, prev *Page[P]) bool {
+		if prev == nil {
+			return true
+		}
+
+		value := cursor(prev.Body)
+		if value == "" {
+			return false
+		}
+
+		req.SetQueryParam(param, value)
+		return true
+	})
+}
+
+// PageNumber sets the query parameter param to the page number,
+// starting with first. The pagination stops after an empty page.
+func PageNumber[P any](param string, first int) Strategy[P] {
+	return StrategyFunc[P](func(req *vrest.Request, prev *Page[P]) bool {
+		number := first
+		if prev != nil {
+			if prev.ItemCount == 0 {
+				return false
+			}
+			number += prev.Number
+		}
+
+		req.SetQueryParam(param, strconv.Itoa(number))
+		return true
+	})
+}
+
+// Offset sets the query parameters offsetParam and limitParam.
+// The pagination stops after a page with less than limit items.
+func Offset[P any](offsetParam, limitParam string, limit int) Strategy[P] {
+	return StrategyFunc[P](func(req *vrest.Request, prev *Page[P]) bool {
+		offset := 0
+		if prev != nil {
+			if prev.ItemCount < limit {
+				return false
+			}
+			offset = prev.Number * limit
+		}
+
+		req.SetQueryParam(offsetParam, strconv.Itoa(offset))
+		req.SetQueryParam(limitParam, strconv.Itoa(limit))
+		return true
+	})
+}
+
+// nextLink returns the target of the link with rel="next"
+// from the given Link header values.
+func nextLink(headerValues []string) (string, bool) {
+	for _, headerValue := range headerValues {
+		for _, link := range splitLinks(headerValue) {
+			target, params, ok := strings.Cut(link, ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				value = strings.Trim(strings.TrimSpace(value), `"`)
+				for _, rel := range strings.Fields(value) {
+					if strings.EqualFold(rel, "next") {
+						return strings.Trim(target, "<>"), true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// splitLinks splits a Link header value into single links.
+// Commas inside of <> are not treated as separators.
+func splitLinks(headerValue string) []string {
+	var links []string
+	inTarget := false
+	start := 0
+	for i, r := range headerValue {
+		switch r {
+		case '<':
+			inTarget = true
+		case '>':
+			inTarget = false
+		case ',':
+			if !inTarget {
+				links = append(links, headerValue[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(links, headerValue[start:])
+}
@@ -0,0 +1,40 @@
+package vrest
+
+import (
+	"io"
+	"sync"
+)
+
+// pipeBody is a request body that is streamed by a goroutine through a pipe.
+// The goroutine is started with the first read, so nothing leaks if the
+// request is never sent. Closing the body stops the goroutine, because
+// all further writes fail.
+type pipeBody struct {
+	write func(w io.Writer) error
+	once  sync.Once
+	pr    *io.PipeReader
+	pw    *io.PipeWriter
+}
+
+func newPipeBody(write func(w io.Writer) error) *pipeBody {
+	pr, pw := io.Pipe()
+	return &pipeBody{
+		write: write,
+		pr:    pr,
+		pw:    pw,
+	}
+}
+
+func (b *pipeBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		go func() {
+			b.pw.CloseWithError(b.write(b.pw))
+		}()
+	})
+	return b.pr.Read(p)
+}
+
+// Close stops the writing goroutine, if it is running.
+func (b *pipeBody) Close() error {
+	return b.pr.Close()
+}
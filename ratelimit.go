@@ -0,0 +1,270 @@
+package vrest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// unixTimeThreshold separates X-RateLimit-Reset values given as delta
+	// seconds from values given as Unix timestamps.
+	unixTimeThreshold = 1_000_000_000
+
+	// bucketSweepInterval is the interval in which idle buckets are evicted.
+	bucketSweepInterval = time.Minute
+)
+
+var ErrRateLimitWait = errors.New("request context done while waiting for rate limit")
+
+// RateLimitConfig configures a client-side token bucket rate limiter.
+type RateLimitConfig struct {
+	// Rate is the number of requests per second.
+	// If it is 0, requests are only limited by response headers.
+	Rate float64
+
+	// Burst is the maximum number of requests sent at once.
+	// It defaults to 1.
+	Burst int
+
+	// Key selects the bucket of a request, for example KeyByHost.
+	// If nil, all requests of the client share one bucket.
+	Key func(req *Request) string
+
+	// AdaptToHeaders adapts the bucket to the rate limit headers of
+	// the responses: X-RateLimit-Remaining, X-RateLimit-Reset,
+	// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy.
+	// A 429 response with Retry-After blocks the bucket, too.
+	AdaptToHeaders bool
+}
+
+// rateLimiter holds the buckets of a rate limit config.
+// Buckets that are full and were not used since the last
+// sweep are evicted, so the map doesn't grow with every key.
+type rateLimiter struct {
+	config    RateLimitConfig
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// SetRateLimit adds a rate limiter as HTTP middleware to the client.
+// Each attempt of a request takes a token from its bucket and waits,
+// until a token is available or the request context is done.
+// Call SetRateLimit multiple times to combine limits, for example
+// one for the whole client and one per host.
+func (c *Client) SetRateLimit(cfg RateLimitConfig) *Client {
+	limiter := &rateLimiter{
+		config:  cfg,
+		buckets: make(map[string]*tokenBucket),
+	}
+	return c.UseHTTP(limiter.middleware)
+}
+
+func (l *rateLimiter) middleware(req *Request, next HTTPDoer) (*http.Response, error) {
+	bucket, wait := l.take(req)
+	if err := bucket.wait(req.Context, wait); err != nil {
+		return nil, err
+	}
+
+	resp, err := next(req)
+	if err == nil && l.config.AdaptToHeaders {
+		bucket.adapt(resp, req.Client.now())
+	}
+	return resp, err
+}
+
+// take takes a token from the bucket of the request and returns the bucket
+// and how long to wait for the token. The token is taken with l.mu locked,
+// so a concurrent sweep can't evict the bucket before it is marked as used.
+func (l *rateLimiter) take(req *Request) (*tokenBucket, time.Duration) {
+	var key string
+	if l.config.Key != nil {
+		key = l.config.Key(req)
+	}
+
+	now := req.Client.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= bucketSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		burst := float64(max(l.config.Burst, 1))
+		bucket = &tokenBucket{
+			rate:   l.config.Rate,
+			burst:  burst,
+			tokens: burst,
+			last:   now,
+		}
+		l.buckets[key] = bucket
+	}
+	return bucket, bucket.take(now)
+}
+
+// sweep evicts the buckets that were not used since the last sweep
+// and are full again, so a new bucket behaves the same.
+// It must be called with l.mu locked.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.idle(l.lastSweep, now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// tokenBucket is a token bucket. Tokens can become negative, which
+// reserves future tokens, so waiting requests are served in order.
+type tokenBucket struct {
+	mu           sync.Mutex
+	rate         float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// take takes a token and returns how long to wait until it is available.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	var wait time.Duration
+	if b.rate > 0 {
+		b.tokens--
+		if b.tokens < 0 {
+			wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		}
+	}
+	return max(wait, b.blockedUntil.Sub(now))
+}
+
+// wait waits for a taken token. If the context is done before, the token is returned.
+func (b *tokenBucket) wait(ctx context.Context, wait time.Duration) error {
+	if err := sleepContext(ctx, wait); err != nil {
+		b.mu.Lock()
+		if b.rate > 0 {
+			b.tokens++
+		}
+		b.mu.Unlock()
+		return errors.Join(ErrRateLimitWait, err)
+	}
+	return nil
+}
+
+// idle reports whether the bucket was not used since the given time
+// and is neither blocked nor missing tokens.
+func (b *tokenBucket) idle(since, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last.After(since) || b.blockedUntil.After(now) {
+		return false
+	}
+	b.refill(now)
+	return b.rate <= 0 || b.tokens >= b.burst
+}
+
+// refill adds the tokens for the time since the last refill.
+// It must be called with b.mu locked.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// adapt adapts the bucket to the rate limit headers of the response.
+func (b *tokenBucket) adapt(resp *http.Response, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if quota, window, ok := parseRateLimitPolicy(resp.Header.Get("RateLimit-Policy")); ok {
+		b.rate = float64(quota) / window.Seconds()
+		b.burst = float64(quota)
+	}
+
+	remaining, hasRemaining := headerInt(resp.Header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	reset, hasReset := headerInt(resp.Header, "X-RateLimit-Reset", "RateLimit-Reset")
+	if hasRemaining {
+		b.refill(now)
+		b.tokens = math.Min(b.tokens, float64(remaining))
+	}
+	if hasRemaining && remaining <= 0 && hasReset {
+		b.block(resetTime(reset, now))
+	}
+
+	if retryAfter, ok := retryAfterOf(resp); ok && resp.StatusCode == http.StatusTooManyRequests {
+		b.block(now.Add(retryAfter))
+	}
+}
+
+// block blocks the bucket until the given time.
+// It must be called with b.mu locked.
+func (b *tokenBucket) block(until time.Time) {
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+// resetTime converts an X-RateLimit-Reset value to a time. Servers send
+// either the seconds until the reset or the Unix time of the reset.
+func resetTime(reset int64, now time.Time) time.Time {
+	if reset >= unixTimeThreshold {
+		return time.Unix(reset, 0)
+	}
+	return now.Add(time.Duration(reset) * time.Second)
+}
+
+// headerInt returns the integer value of the first of the given headers that is set.
+func headerInt(header http.Header, keys ...string) (int64, bool) {
+	for _, key := range keys {
+		if value := header.Get(key); value != "" {
+			n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// parseRateLimitPolicy parses the first policy of a RateLimit-Policy header.
+// It supports both "100;w=60" and `"default";q=100;w=60`.
+func parseRateLimitPolicy(value string) (int64, time.Duration, bool) {
+	if value == "" {
+		return 0, 0, false
+	}
+
+	policy, _, _ := strings.Cut(value, ",")
+	var quota, window int64
+	for i, param := range strings.Split(policy, ";") {
+		key, arg, hasArg := strings.Cut(strings.TrimSpace(param), "=")
+		n, err := strconv.ParseInt(strings.Trim(arg, `"`), 10, 64)
+		switch {
+		case i == 0 && !hasArg:
+			// the quota or the name of the policy
+			if n, err = strconv.ParseInt(key, 10, 64); err == nil {
+				quota = n
+			}
+		case key == "q" && err == nil:
+			quota = n
+		case key == "w" && err == nil:
+			window = n
+		}
+	}
+
+	if quota <= 0 || window <= 0 {
+		return 0, 0, false
+	}
+	return quota, time.Duration(window) * time.Second, true
+}
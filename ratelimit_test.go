@@ -0,0 +1,138 @@
+package vrest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_SetRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetRateLimit(RateLimitConfig{Rate: 20, Burst: 1, Key: KeyByHost})
+
+	start := time.Now()
+	for range 4 {
+		if err := c.NewRequest().DoGet("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Fatalf("requests were not rate limited, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c = NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetRateLimit(RateLimitConfig{Rate: 0.1})
+	if err := c.NewRequestWithContext(ctx).DoGet("/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := c.NewRequestWithContext(ctx).DoGet("/")
+	if !errors.Is(err, ErrRateLimitWait) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTokenBucket_adapt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		statusCode       int
+		header           map[string]string
+		wantRate         float64
+		wantTokens       float64
+		wantBlockedUntil time.Time
+	}{
+		{
+			name:       "remaining",
+			header:     map[string]string{"X-RateLimit-Remaining": "2", "X-RateLimit-Reset": "30"},
+			wantRate:   1,
+			wantTokens: 2,
+		},
+		{
+			name:             "exhausted with delta reset",
+			header:           map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "30"},
+			wantRate:         1,
+			wantBlockedUntil: now.Add(30 * time.Second),
+		},
+		{
+			name:             "exhausted with unix reset",
+			header:           map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "1714564860"},
+			wantRate:         1,
+			wantBlockedUntil: now.Add(time.Minute),
+		},
+		{
+			name:       "policy",
+			header:     map[string]string{"RateLimit-Policy": `"default";q=100;w=10`},
+			wantRate:   10,
+			wantTokens: 5,
+		},
+		{
+			name:             "too many requests",
+			statusCode:       http.StatusTooManyRequests,
+			header:           map[string]string{"Retry-After": "5"},
+			wantRate:         1,
+			wantTokens:       5,
+			wantBlockedUntil: now.Add(5 * time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.statusCode, Header: http.Header{}}
+			for key, value := range tt.header {
+				resp.Header.Set(key, value)
+			}
+
+			bucket := &tokenBucket{rate: 1, burst: 5, tokens: 5, last: now}
+			bucket.adapt(resp, now)
+
+			if bucket.rate != tt.wantRate || bucket.tokens != tt.wantTokens || !bucket.blockedUntil.Equal(tt.wantBlockedUntil) {
+				t.Fatalf("unexpected bucket: rate %v, tokens %v, blocked until %v",
+					bucket.rate, bucket.tokens, bucket.blockedUntil)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_sweep(t *testing.T) {
+	now := time.Now()
+	c := New().SetClock(func() time.Time { return now })
+	l := &rateLimiter{
+		config:  RateLimitConfig{Rate: 1, Burst: 2, Key: KeyByRoute},
+		buckets: make(map[string]*tokenBucket),
+	}
+	take := func(path string) *tokenBucket {
+		t.Helper()
+		req := c.NewRequest()
+		raw, err := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		req.Raw = raw
+		bucket, _ := l.take(req)
+		return bucket
+	}
+
+	// the token of the idle bucket is refilled until the sweep
+	take("/idle")
+	now = now.Add(bucketSweepInterval / 2)
+	used := take("/used")
+
+	// the next bucket sweeps the buckets that were not used since the last sweep
+	now = now.Add(bucketSweepInterval / 2)
+	take("/new")
+	if _, ok := l.buckets["GET example.com/idle"]; ok || len(l.buckets) != 2 || l.buckets["GET example.com/used"] != used {
+		t.Fatalf("unexpected buckets after sweep: %v", l.buckets)
+	}
+}
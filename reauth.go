@@ -0,0 +1,72 @@
+package vrest
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+)
+
+// ReauthMode defines if a request is replayed with a new token,
+// after the server rejected the token with status 401.
+type ReauthMode int
+
+const (
+	// ReauthOnUnauthorized invalidates the token on any 401 response.
+	// This is the default.
+	ReauthOnUnauthorized ReauthMode = iota
+
+	// ReauthOnInvalidToken invalidates the token only on 401 responses
+	// with the header WWW-Authenticate containing error="invalid_token".
+	ReauthOnInvalidToken
+
+	// ReauthDisabled never invalidates the token.
+	ReauthDisabled
+)
+
+// SetReauthMode sets when a token is invalidated and the request is replayed
+// with a new token from the token getter, after the server responded with 401.
+// Each request is replayed at most once. The replay doesn't count
+// as an attempt of the retry policy.
+func (c *Client) SetReauthMode(mode ReauthMode) *Client {
+	c.ReauthMode = mode
+	return c
+}
+
+var invalidTokenPattern = regexp.MustCompile(`(?i)error\s*=\s*"?invalid_token"?`)
+
+// shouldReauthenticate reports whether the request failed, because
+// the server rejected the token, and can be replayed with a new token.
+func (req *Request) shouldReauthenticate(err error) bool {
+	if err == nil || req.Reauthenticated || req.token == nil || !req.canResendBody() {
+		return false
+	}
+
+	if req.Response.StatusCode() != http.StatusUnauthorized {
+		return false
+	}
+
+	switch req.Client.ReauthMode {
+	case ReauthOnUnauthorized:
+		return true
+	case ReauthOnInvalidToken:
+		for _, value := range req.Response.Header().Values("WWW-Authenticate") {
+			if invalidTokenPattern.MatchString(value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// reauthenticate invalidates the rejected token and sets a new one.
+func (req *Request) reauthenticate(err error) error {
+	req.Reauthenticated = true
+	req.Client.invalidateToken(req.TokenScope, req.token)
+
+	if tokenErr := req.setToken(); tokenErr != nil {
+		return errors.Join(err, tokenErr)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package vrest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshFraction   = 0.8
+	defaultRefreshMinBackoff = time.Second
+	defaultRefreshMaxBackoff = time.Minute
+)
+
+// BackgroundRefreshConfig configures the proactive background token refresh.
+type BackgroundRefreshConfig struct {
+	// Fraction is the fraction (0 to 1) of the token lifetime after
+	// which the token is renewed. It defaults to 0.8.
+	Fraction float64
+
+	// MinBackoff is the wait time before retrying a failed refresh.
+	// It is doubled for each failure. It defaults to 1 second.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the wait time between retries.
+	// It defaults to 1 minute.
+	MaxBackoff time.Duration
+}
+
+// backgroundRefreshKey marks the context of the background refresher.
+type backgroundRefreshKey struct{}
+
+// isBackgroundRefresh reports whether the token is refreshed by the background refresher.
+func isBackgroundRefresh(ctx context.Context) bool {
+	background, _ := ctx.Value(backgroundRefreshKey{}).(bool)
+	return background
+}
+
+// tokenRefresher runs one background goroutine per token scope.
+type tokenRefresher struct {
+	config BackgroundRefreshConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// sleep waits for the given duration or until the context is done.
+	// Tests replace it to drive the refresher with a fake clock.
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// SetBackgroundTokenRefresh enables the proactive background refresh of tokens.
+// Tokens are renewed at a fraction of their lifetime, so requests don't block
+// on expired tokens. Failed refreshes are retried with backoff, as long as the
+// old token is still valid. The token must implement ExpiringToken, which
+// OAuthToken does. Call Close to stop the background refresh.
+func (c *Client) SetBackgroundTokenRefresh(cfg BackgroundRefreshConfig) *Client {
+	if cfg.Fraction <= 0 || cfg.Fraction > 1 {
+		cfg.Fraction = defaultRefreshFraction
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultRefreshMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultRefreshMaxBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), backgroundRefreshKey{}, true))
+	c.refresher = &tokenRefresher{
+		config: cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		sleep:  sleepContext,
+	}
+	return c
+}
+
+// Close stops the background token refresh and waits until it is stopped.
+// The client can still be used after Close, tokens are then
+// refreshed on demand again.
+func (c *Client) Close() error {
+	r := c.refresher
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
+
+// startTokenRefresher starts the background refresher for the slot,
+// if background refresh is enabled and it is not running yet.
+// It must be called with c.tokens.mu locked.
+func (c *Client) startTokenRefresher(scope TokenScope, slot *tokenSlot) {
+	r := c.refresher
+	if r == nil || slot.refreshing {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	slot.refreshing = true
+	r.wg.Add(1)
+	go c.runTokenRefresher(r, scope, slot)
+}
+
+// runTokenRefresher renews the token of the slot until the client is closed
+// or the token can't be renewed before it expires.
+func (c *Client) runTokenRefresher(r *tokenRefresher, scope TokenScope, slot *tokenSlot) {
+	defer r.wg.Done()
+	defer func() {
+		c.tokens.mu.Lock()
+		slot.refreshing = false
+		c.tokens.mu.Unlock()
+	}()
+
+	for {
+		entry := slot.entry.Load()
+		token, ok := entry.token.(ExpiringToken)
+		if !ok {
+			return
+		}
+
+		expiresAt := token.ExpiresAt()
+		if !expiresAt.After(entry.obtainedAt) {
+			return
+		}
+
+		lifetime := expiresAt.Sub(entry.obtainedAt)
+		refreshAt := entry.obtainedAt.Add(time.Duration(float64(lifetime) * r.config.Fraction))
+		if entry.invalidated {
+			refreshAt = c.now()
+		}
+
+		if err := r.sleep(r.ctx, refreshAt.Sub(c.now())); err != nil {
+			return
+		}
+
+		if !c.refreshTokenWithBackoff(r, scope, expiresAt) {
+			return
+		}
+	}
+}
+
+// refreshTokenWithBackoff refreshes the token of the scope and retries
+// with backoff until it succeeded or the old token expired.
+func (c *Client) refreshTokenWithBackoff(r *tokenRefresher, scope TokenScope, expiresAt time.Time) bool {
+	backoff := r.config.MinBackoff
+	for {
+		_, err := c.loadToken(r.ctx, scope, true)
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, ErrInteractiveGrantRequired) {
+			return false
+		}
+
+		c.logger.LogAttrs(r.ctx, slog.LevelWarn,
+			"background token refresh failed",
+			slog.String("error", err.Error()))
+
+		if c.now().Add(backoff).After(expiresAt) {
+			return false
+		}
+		if r.sleep(r.ctx, backoff) != nil {
+			return false
+		}
+		backoff = min(2*backoff, r.config.MaxBackoff)
+	}
+}
@@ -0,0 +1,113 @@
+package vrest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testExpiringTokenGetter struct {
+	calls    atomic.Int32
+	failures atomic.Int32
+	lifetime time.Duration
+	clock    func() time.Time
+}
+
+func (g *testExpiringTokenGetter) GetToken(_ context.Context, _ Token) (Token, error) {
+	calls := g.calls.Add(1)
+	if calls > 1 && g.failures.Add(-1) >= 0 {
+		return nil, errors.New("token endpoint unavailable")
+	}
+
+	now := time.Now
+	if g.clock != nil {
+		now = g.clock
+	}
+	return &OAuthToken{
+		AccessToken: "token",
+		ValidUntil:  now().Add(g.lifetime),
+		clock:       g.clock,
+	}, nil
+}
+
+func TestClient_backgroundTokenRefresh(t *testing.T) {
+	var now atomic.Pointer[time.Time]
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now.Store(&start)
+	clock := func() time.Time { return *now.Load() }
+
+	getter := &testExpiringTokenGetter{lifetime: time.Hour, clock: clock}
+	getter.failures.Store(2)
+
+	c := New().
+		SetClock(clock).
+		SetTokenGetter(getter).
+		SetBackgroundTokenRefresh(BackgroundRefreshConfig{
+			Fraction:   0.25,
+			MinBackoff: time.Minute,
+			MaxBackoff: time.Hour,
+		})
+
+	// the refresher sleeps with the fake clock, each sleep is
+	// passed to the test, which advances the clock and wakes it
+	sleeps := make(chan time.Duration)
+	wake := make(chan struct{})
+	c.refresher.sleep = func(ctx context.Context, d time.Duration) error {
+		select {
+		case sleeps <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case <-wake:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	advance := func(want time.Duration) {
+		t.Helper()
+		select {
+		case d := <-sleeps:
+			if d != want {
+				t.Fatalf("unexpected sleep %v, want %v", d, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("refresher did not sleep, calls: %d", getter.calls.Load())
+		}
+		next := now.Load().Add(want)
+		now.Store(&next)
+		wake <- struct{}{}
+	}
+
+	if _, err := c.getValidToken(context.Background(), TokenScope{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// refresh after a quarter of the lifetime, two failures with backoff
+	advance(15 * time.Minute)
+	advance(time.Minute)
+	advance(2 * time.Minute)
+
+	// the refreshed token is renewed again after a quarter of its lifetime
+	select {
+	case d := <-sleeps:
+		if d != 15*time.Minute {
+			t.Fatalf("unexpected sleep %v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("token was not refreshed in background, calls: %d", getter.calls.Load())
+	}
+	if getter.calls.Load() != 4 {
+		t.Fatalf("unexpected number of token requests: %d", getter.calls.Load())
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if getter.calls.Load() != 4 {
+		t.Fatalf("token was refreshed after Close, calls: %d", getter.calls.Load())
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 )
 
@@ -26,6 +27,32 @@ type Request struct {
 	Overridable   Overridables
 	TraceBody     bool
 	TokenRequest  bool
+	TokenScope    TokenScope
+	RetryPolicy   *RetryPolicy
+
+	Middlewares     []Middleware
+	HTTPMiddlewares []HTTPMiddleware
+
+	// MultipartParts are sent as multipart/form-data body.
+	MultipartParts []MultipartPart
+
+	// SSE configures DoEvents.
+	SSE SSEConfig
+
+	// CircuitState is the state of the circuit breaker when the
+	// current attempt was made. It is empty without circuit breaker.
+	CircuitState CircuitState
+
+	// Attempt is the number of the current attempt, starting at 1.
+	// It is greater than 1 if the request is retried.
+	Attempt int
+
+	// Reauthenticated is set if the request was replayed with a
+	// new token, because the server rejected the previous one.
+	Reauthenticated bool
+
+	// token is the token entry used for the request.
+	token *tokenEntry
 }
 
 // NewRequest is a shortcut for NewRequestWithContext(context.Background()).
@@ -43,10 +70,16 @@ func (c *Client) NewRequestWithContext(ctx context.Context) *Request {
 		Query:       make(url.Values),
 		Overridable: c.Overridable,
 		TraceBody:   c.TraceBodies,
+		RetryPolicy: c.RetryPolicy,
+		// clone the middlewares, so per request middlewares
+		// don't modify the client's slices
+		Middlewares:     slices.Clone(c.Middlewares),
+		HTTPMiddlewares: slices.Clone(c.HTTPMiddlewares),
 		Response: Response{
-			BodyLimit:   c.ResponseBodyLimit,
-			TraceBody:   c.TraceBodies,
-			DoUnmarshal: true,
+			BodyLimit:       c.ResponseBodyLimit,
+			BodyLimitPolicy: c.ResponseBodyLimitPolicy,
+			TraceBody:       c.TraceBodies,
+			DoUnmarshal:     true,
 		},
 	}
 
@@ -61,13 +94,42 @@ func (c *Client) NewRequestWithContext(ctx context.Context) *Request {
 	return req
 }
 
-func (req *Request) makeHTTPRequest() error {
+// clone returns a copy of the request that can be sent independently.
+// It must be called before the request is sent, because the
+// response is copied as well.
+func (req *Request) clone() *Request {
+	clone := *req
+	clone.Raw = nil
+	clone.Attempt = 0
+	clone.Header = req.Header.Clone()
+	clone.Query = make(url.Values, len(req.Query))
+	for key, values := range req.Query {
+		clone.Query[key] = slices.Clone(values)
+	}
+	clone.Middlewares = slices.Clone(req.Middlewares)
+	clone.HTTPMiddlewares = slices.Clone(req.HTTPMiddlewares)
+	return &clone
+}
+
+func (req *Request) makeHTTPRequest() (err error) {
 	if req.Raw != nil {
 		return nil
 	}
 
 	reqURL := req.makeRequestURL(req.Client.BaseURL, req.Path)
 
+	if len(req.MultipartParts) > 0 {
+		if err = req.prepareMultipartBody(); err != nil {
+			return err
+		}
+		defer func() {
+			// the request can be sent again, so the body must be created again
+			if err != nil {
+				req.resetMultipartBody()
+			}
+		}()
+	}
+
 	reqBodyReader, bodyBytes, err := req.makeRequestBody(req.Body, req.ContentType())
 	if err != nil {
 		return err
@@ -91,12 +153,10 @@ func (req *Request) makeHTTPRequest() error {
 		}
 	}
 
-	if req.Client.TokenGetter != nil && !req.TokenRequest {
-		token, err := req.Client.getValidToken(req.Context)
-		if err != nil {
-			return fmt.Errorf("failed to get token for %s %s: %w", req.Method, reqURL, err)
+	if req.usesTokenGetter() {
+		if err := req.setToken(); err != nil {
+			return err
 		}
-		req.SetBearerAuth(token.Token())
 	}
 
 	if len(req.Query) > 0 {
@@ -106,6 +166,24 @@ func (req *Request) makeHTTPRequest() error {
 	return nil
 }
 
+func (req *Request) usesTokenGetter() bool {
+	return req.Client.TokenGetter != nil && !req.TokenRequest
+}
+
+// setToken gets a valid token from the client and
+// sets it as bearer auth for the request.
+func (req *Request) setToken() error {
+	entry, err := req.Client.getValidToken(req.Context, req.TokenScope)
+	if err != nil {
+		return fmt.Errorf("failed to get token for %s %s: %w", req.Method, req.Raw.URL, err)
+	}
+
+	req.token = entry
+	req.SetBearerAuth(entry.token.Token())
+	req.Raw.Header.Set("Authorization", req.Header.Get("Authorization"))
+	return nil
+}
+
 func (req *Request) makeRequestBody(body interface{}, contentType string) (io.Reader, []byte, error) {
 	switch bodyValue := body.(type) {
 	case io.Reader:
@@ -138,27 +216,34 @@ func (req *Request) marshalRequestBody(body interface{}, contentType string) ([]
 		return nil, fmt.Errorf("content type must not be empty")
 	}
 
-	if IsJSONContentType(contentType) {
-		return req.Overridable.JSONMarshal(req, body)
-	}
-
-	if IsXMLContentType(contentType) {
-		return req.Overridable.XMLMarshal(req, body)
+	codec, ok := req.Client.CodecFor(contentType)
+	if !ok {
+		return nil, fmt.Errorf("don't know how to marshal request body with Content-Type \"%s\"", contentType)
 	}
 
-	return nil, fmt.Errorf("don't know how to marshal request body with Content-Type \"%s\"", contentType)
+	return codec.Marshal(req, body)
 }
 
+// makeRequestURL joins the base URL and the request path.
+// Absolute request URLs, like links of the server, are used as they are.
 func (req *Request) makeRequestURL(baseURL, requestPath string) string {
 	if req.BaseURL != "" {
 		baseURL = req.BaseURL
 	}
+	if isAbsoluteURL(requestPath) {
+		return requestPath
+	}
 	if baseURL != "" && !strings.Contains(requestPath, baseURL) {
 		return baseURL + requestPath
 	}
 	return requestPath
 }
 
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 // SetContext sets the context of the request.
 func (req *Request) SetContext(ctx context.Context) *Request {
 	req.Context = ctx
@@ -167,6 +252,7 @@ func (req *Request) SetContext(ctx context.Context) *Request {
 
 // SetBaseURL overrides the base URL of the client for this request.
 // This is only needed in rare cases, like token requests.
+// Like the base URL of the client, it is not used for absolute URLs.
 func (req *Request) SetBaseURL(baseURL string) *Request {
 	req.BaseURL = baseURL
 	return req
@@ -179,6 +265,27 @@ func (req *Request) SetBody(body any) *Request {
 	return req
 }
 
+// SetRetryPolicy overrides the retry policy of the client for this request.
+func (req *Request) SetRetryPolicy(policy RetryPolicy) *Request {
+	req.RetryPolicy = &policy
+	return req
+}
+
+// DisableRetry disables retries for this request.
+func (req *Request) DisableRetry() *Request {
+	req.RetryPolicy = nil
+	return req
+}
+
+// SetFormData sets the body of the request to the given form data and
+// the Content-Type to "application/x-www-form-urlencoded".
+// See EncodeForm for the supported types.
+func (req *Request) SetFormData(data any) *Request {
+	return req.
+		SetContentType("application/x-www-form-urlencoded").
+		SetBody(data)
+}
+
 // SetTraceRequestBody sets the TraceBody field of the request.
 // Used to determine if the request body should be traced.
 func (req *Request) SetTraceRequestBody(value bool) *Request {
@@ -238,6 +345,12 @@ func (req *Request) SetResponseBodyLimit(limit int64) *Request {
 	return req
 }
 
+// SetResponseBodyLimitPolicy sets what happens if the response body exceeds the limit.
+func (req *Request) SetResponseBodyLimitPolicy(policy BodyLimitPolicy) *Request {
+	req.Response.BodyLimitPolicy = policy
+	return req
+}
+
 // SetResponseContentLengthPtr sets the Content-Length based on a pointer.
 // If the server provides the Content-Length header, the value will be set.
 // This can be used if you want to handle the response body as a stream,
@@ -293,6 +406,14 @@ func (req *Request) SetTokenRequest() *Request {
 	return req
 }
 
+// SetTokenScope selects the token of the token getter that is used for
+// this request. The client caches one token per scope. The token getter
+// must implement ScopedTokenGetter, which the OAuth token getter does.
+func (req *Request) SetTokenScope(scope TokenScope) *Request {
+	req.TokenScope = scope
+	return req
+}
+
 // SetContentTypeJSON sets the Content-Type header of the request to "application/json".
 // You can also set this as the default in the Client setup.
 func (req *Request) SetContentTypeJSON() *Request {
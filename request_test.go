@@ -19,3 +19,24 @@ func TestRequest_setResponseBody_NoPointer(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestRequest_makeRequestURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		path    string
+		want    string
+	}{
+		{name: "path", baseURL: "https://api.example.com/v1", path: "/orders", want: "https://api.example.com/v1/orders"},
+		{name: "without base URL", path: "https://api.example.com/orders", want: "https://api.example.com/orders"},
+		{name: "absolute URL", baseURL: "https://api.example.com/v1", path: "https://cdn.example.com/orders?b=1&a=%2F", want: "https://cdn.example.com/orders?b=1&a=%2F"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := New().SetBaseURL(tt.baseURL).NewRequest()
+			if got := req.makeRequestURL(req.Client.BaseURL, tt.path); got != tt.want {
+				t.Fatalf("unexpected URL: %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,32 @@
 package vrest
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
 )
 
+// BodyLimitPolicy defines what happens if a response body exceeds the body limit.
+type BodyLimitPolicy int
+
+const (
+	// BodyLimitError fails the request with ErrResponseBodyTooLarge.
+	// Bodies of unsuccessful responses are truncated and flagged instead,
+	// so the request still fails with *HTTPError. This is the default.
+	BodyLimitError BodyLimitPolicy = iota
+
+	// BodyLimitTruncate silently truncates the response body to the limit.
+	BodyLimitTruncate
+
+	// BodyLimitTruncateAndFlag truncates the response body to the limit
+	// and sets Response.Truncated.
+	BodyLimitTruncateAndFlag
+)
+
+var ErrResponseBodyTooLarge = errors.New("response body too large")
+
 type Response struct {
 	Raw         *http.Response
 	Error       error
@@ -19,6 +39,24 @@ type Response struct {
 	TraceBody   bool
 	DoUnmarshal bool
 
+	// BodyLimitPolicy defines what happens if the body exceeds BodyLimit.
+	BodyLimitPolicy BodyLimitPolicy
+
+	// BodySize is the number of body bytes read from the server.
+	// If the body exceeded BodyLimit, the rest of the body is not read
+	// and it is the Content-Length of the response or, if it is unknown,
+	// BodyLimit+1.
+	BodySize int64
+
+	// Truncated is set if the body was truncated with the policy
+	// BodyLimitTruncateAndFlag or the truncated body of an unsuccessful
+	// response was kept with the policy BodyLimitError.
+	Truncated bool
+
+	// CacheStatus is set if the response passed the HTTP cache.
+	// It tells if the response was served from the cache.
+	CacheStatus CacheStatus
+
 	ContentLengthPtr *int64
 
 	SuccessStatusCodes []int
@@ -26,6 +64,7 @@ type Response struct {
 
 // processHTTPResponse processes the raw http response and sets the response fields.
 // It returns an error if the response is not successful or if the response body could not be read.
+// Unsuccessful responses are returned as *HTTPError.
 func (req *Request) processHTTPResponse(rawResp *http.Response, err error) error {
 	req.Response.Raw = rawResp
 	if err != nil {
@@ -47,8 +86,7 @@ func (req *Request) processHTTPResponse(rawResp *http.Response, err error) error
 	success := req.Overridable.IsSuccess(req)
 	if req.Response.HasEmptyBody() {
 		if !success {
-			return fmt.Errorf("http request %s %s failed with status code %d",
-				req.Raw.Method, req.Raw.URL, req.Response.StatusCode())
+			return req.newHTTPError(nil)
 		}
 		return nil
 	}
@@ -71,18 +109,9 @@ func (req *Request) processHTTPResponse(rawResp *http.Response, err error) error
 
 	if !success {
 		if didUnmarshal {
-			errMsg := fmt.Sprintf("http request %s %s failed: status %d", req.Raw.Method, req.Raw.URL, req.Response.StatusCode())
-			switch e := responseValue.(type) {
-			case error:
-				return fmt.Errorf("%s: %w", errMsg, e)
-			default:
-				return fmt.Errorf("%s: %s", errMsg, responseValue)
-			}
+			return req.newHTTPError(responseValue)
 		}
-
-		msg := string(req.Response.BodyBytes)
-		return fmt.Errorf("http request %s %s failed: status %d: %s", req.Raw.Method, req.Raw.URL,
-			req.Response.StatusCode(), msg)
+		return req.newHTTPError(nil)
 	}
 
 	return nil
@@ -92,7 +121,8 @@ func (req *Request) processHTTPResponse(rawResp *http.Response, err error) error
 // It returns an error if the response body could not be read.
 // It does not read the body if the response body is of type io.ReadCloser.
 // It checks for a response body limit and reads only up to that limit, if
-// request.SetResponseBodyLimit was called.
+// request.SetResponseBodyLimit was called. One extra byte is read to detect
+// bodies exceeding the limit, which are handled according to the body limit policy.
 func (req *Request) readResponseBody() error {
 	if req.Response.Raw.Body == nil {
 		return nil
@@ -107,13 +137,33 @@ func (req *Request) readResponseBody() error {
 		}
 	}
 
+	limit := req.Response.BodyLimit
 	var r io.Reader = req.Response.Raw.Body
-	if req.Response.BodyLimit > 0 {
-		r = io.LimitReader(r, req.Response.BodyLimit)
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
 	}
 
 	var err error
 	req.Response.BodyBytes, err = io.ReadAll(r)
+	req.Response.BodySize = int64(len(req.Response.BodyBytes))
+	if err != nil {
+		return err
+	}
+
+	if limit > 0 && req.Response.BodySize > limit {
+		req.Response.BodyBytes = req.Response.BodyBytes[:limit]
+		req.Response.BodySize = max(req.Response.BodySize, req.Response.Raw.ContentLength)
+		switch {
+		case req.Response.BodyLimitPolicy == BodyLimitTruncate:
+		case req.Response.BodyLimitPolicy == BodyLimitTruncateAndFlag,
+			!req.Overridable.IsSuccess(req):
+			// the truncated body of an error response is kept for the HTTPError
+			req.Response.Truncated = true
+		default:
+			return fmt.Errorf("%w: limit is %d bytes", ErrResponseBodyTooLarge, limit)
+		}
+	}
+
 	if len(req.Response.BodyBytes) > 0 && req.Response.WantsRawByteArray() {
 		if responseBytesPointer, ok := req.Response.Body.(*[]byte); ok && responseBytesPointer != nil {
 			*responseBytesPointer = req.Response.BodyBytes
@@ -121,10 +171,11 @@ func (req *Request) readResponseBody() error {
 		}
 	}
 
-	return err
+	return nil
 }
 
-// unmarshalResponseBody unmarshals the response body into the given value.
+// unmarshalResponseBody unmarshals the response body into the given value
+// using the codec registered for the response content type.
 // It returns true if the response body was unmarshaled, false otherwise.
 // If there is a body and it was not unmarshaled, an error is returned.
 func (req *Request) unmarshalResponseBody(value interface{}) (bool, error) {
@@ -138,17 +189,21 @@ func (req *Request) unmarshalResponseBody(value interface{}) (bool, error) {
 		return false, nil
 	}
 
-	var err error
-	if req.Response.ForceJSON || IsJSONContentType(req.Response.ContentType()) {
-		err = req.Overridable.JSONUnmarshal(req, req.Response.BodyBytes, value)
-		if err != nil {
-			return false, err
-		}
-		return true, nil
+	contentType := req.Response.ContentType()
+	switch {
+	case req.Response.ForceJSON:
+		contentType = "application/json"
+	case req.Response.ForceXML:
+		contentType = "application/xml"
 	}
 
-	if req.Response.ForceXML || IsXMLContentType(req.Response.ContentType()) {
-		err = req.Overridable.XMLUnmarshal(req, req.Response.BodyBytes, value)
+	if codec, ok := req.Client.CodecFor(contentType); ok {
+		err := codec.Unmarshal(req, req.Response.BodyBytes, value)
+		if err != nil && len(req.Response.BodyBytes) == 0 && errors.Is(err, ErrResponseNotUnmarshaled) {
+			// an empty body the codec doesn't support for the value
+			// is no error, like an empty body without codec
+			return false, nil
+		}
 		if err != nil {
 			return false, err
 		}
@@ -1,7 +1,10 @@
 package vrest
 
 import (
+	"cmp"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
@@ -92,3 +95,71 @@ func mustParseTime(t *testing.T, value string) time.Time {
 func toPtr[T any](v T) *T {
 	return &v
 }
+
+func TestGet(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL)
+
+	got, err := Get[time.Time](c.NewRequest(), "/unmarshal/json/time")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := mustParseTime(t, testTimeValue); !got.Equal(want) {
+		t.Fatalf("unexpected response body:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestRequest_readResponseBody_limit(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        BodyLimitPolicy
+		status        int
+		body          string
+		wantErr       error
+		wantHTTPError bool
+		wantBody      string
+		wantBodySize  int64
+		wantTruncated bool
+	}{
+		{name: "within limit", body: "1234", wantBody: "1234", wantBodySize: 4},
+		{name: "error", body: "123456", wantErr: ErrResponseBodyTooLarge},
+		{name: "error response", status: http.StatusBadRequest, body: "123456", wantHTTPError: true, wantBody: "1234", wantBodySize: 6, wantTruncated: true},
+		{name: "truncate", policy: BodyLimitTruncate, body: "123456", wantBody: "1234", wantBodySize: 6},
+		{name: "truncate and flag", policy: BodyLimitTruncateAndFlag, body: "123456", wantBody: "1234", wantBodySize: 6, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(cmp.Or(tt.status, http.StatusOK))
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			c := NewWithClient(ts.Client()).
+				SetBaseURL(ts.URL).
+				SetResponseBodyLimit(4).
+				SetResponseBodyLimitPolicy(tt.policy)
+
+			var body []byte
+			req := c.NewRequest().SetResponseBody(&body)
+			err := req.DoGet("/")
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) != tt.wantHTTPError || !tt.wantHTTPError && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error:\ngot:  %v\nwant: %v", err, tt.wantErr)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+
+			if string(req.Response.BodyBytes) != tt.wantBody || req.Response.Truncated != tt.wantTruncated {
+				t.Fatalf("unexpected body %q, truncated %v", req.Response.BodyBytes, req.Response.Truncated)
+			}
+			if req.Response.BodySize != tt.wantBodySize {
+				t.Fatalf("unexpected body size %d", req.Response.BodySize)
+			}
+		})
+	}
+}
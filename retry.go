@@ -0,0 +1,242 @@
+package vrest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 200 * time.Millisecond
+	defaultRetryMaxBackoff     = 10 * time.Second
+	defaultRetryMultiplier     = 2
+	defaultRetryJitter         = 0.2
+)
+
+// RetryPolicy defines if and how failed requests are retried.
+// A request is only retried if its body can be sent again, which is
+// the case for all bodies except io.Reader values.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+
+	// InitialBackoff is the wait time before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait time between two attempts.
+	// If it is 0, the wait time is not capped.
+	MaxBackoff time.Duration
+
+	// Multiplier is applied to the backoff after each retry.
+	// Values below 1 are treated as 1.
+	Multiplier float64
+
+	// Jitter is the fraction (0 to 1) of the backoff that is randomized,
+	// so concurrent clients don't retry at the same time.
+	Jitter float64
+
+	// StatusCodes are the response status codes that trigger a retry.
+	StatusCodes []int
+
+	// Methods restricts retries to the given HTTP methods.
+	// If empty, requests with any method are retried.
+	Methods []string
+
+	// RetryNetworkErrors enables retries for errors returned by the
+	// HTTP client, for example connection resets or timeouts.
+	// Errors caused by the request context are never retried.
+	RetryNetworkErrors bool
+
+	// RespectRetryAfter uses the Retry-After header of 429 and 503
+	// responses as wait time. If the server asks to wait longer than
+	// MaxBackoff, the request is not retried.
+	RespectRetryAfter bool
+
+	// ShouldRetry replaces the default retry decision, if set.
+	// It is called after each failed attempt, err is the attempt's error.
+	// Requests rejected with ErrCircuitOpen are never retried.
+	ShouldRetry func(req *Request, err error) bool
+}
+
+// DefaultRetryPolicy returns a retry policy with sensible defaults:
+// 3 attempts with exponential backoff for idempotent methods on network errors
+// and the status codes 429, 502, 503 and 504.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: defaultRetryInitialBackoff,
+		MaxBackoff:     defaultRetryMaxBackoff,
+		Multiplier:     defaultRetryMultiplier,
+		Jitter:         defaultRetryJitter,
+		StatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		Methods: []string{
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodOptions,
+			http.MethodPut,
+			http.MethodDelete,
+			http.MethodTrace,
+		},
+		RetryNetworkErrors: true,
+		RespectRetryAfter:  true,
+	}
+}
+
+// retryAttempt returns the number of attempts counted by the retry policy.
+// The replay with a new token after a rejected token is not counted.
+func (req *Request) retryAttempt() int {
+	if req.Reauthenticated {
+		return req.Attempt - 1
+	}
+	return req.Attempt
+}
+
+// retryWait reports whether the request should be retried after the
+// attempt that just finished and how long to wait before the next attempt.
+func (req *Request) retryWait(err error) (time.Duration, bool) {
+	p := req.RetryPolicy
+	if p == nil || err == nil || req.retryAttempt() >= p.MaxAttempts || !req.canResendBody() {
+		return 0, false
+	}
+
+	// an open circuit fails all attempts immediately, even a custom
+	// retry decision must not retry it
+	if errors.Is(err, ErrCircuitOpen) {
+		return 0, false
+	}
+
+	if p.ShouldRetry != nil {
+		if !p.ShouldRetry(req, err) {
+			return 0, false
+		}
+	} else if !p.isRetryable(req, err) {
+		return 0, false
+	}
+
+	wait := p.backoff(req.retryAttempt())
+	if p.RespectRetryAfter {
+		if retryAfter, ok := retryAfterOf(req.Response.Raw); ok {
+			if p.MaxBackoff > 0 && retryAfter > p.MaxBackoff {
+				return 0, false
+			}
+			wait = retryAfter
+		}
+	}
+
+	return wait, true
+}
+
+func (p *RetryPolicy) isRetryable(req *Request, err error) bool {
+	if len(p.Methods) > 0 && !slices.Contains(p.Methods, req.Method) {
+		return false
+	}
+	if req.Response.Raw == nil {
+		// no response means the HTTP client failed
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		return p.RetryNetworkErrors
+	}
+
+	return slices.Contains(p.StatusCodes, req.Response.StatusCode())
+}
+
+// backoff returns the wait time after the given attempt (starting at 1).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := math.Max(p.Multiplier, 1)
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		wait = math.Min(wait, float64(p.MaxBackoff))
+	}
+
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		wait = wait*(1-jitter) + wait*jitter*rand.Float64() // nolint:gosec
+	}
+
+	return time.Duration(wait)
+}
+
+// retryAfterOf parses the Retry-After header of 429 and 503 responses.
+// The header can contain either seconds or an HTTP date.
+func retryAfterOf(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// canResendBody reports whether the request body can be sent again.
+// Bodies given as io.Reader are consumed by the first attempt.
+func (req *Request) canResendBody() bool {
+	return req.Body == nil || req.BodyBytes != nil
+}
+
+// prepareRetry resets the request body and the response
+// of the previous attempt, so the request can be sent again.
+func (req *Request) prepareRetry() {
+	if req.BodyBytes != nil {
+		req.Raw.Body = io.NopCloser(bytes.NewReader(req.BodyBytes))
+	}
+
+	req.Response.Raw = nil
+	req.Response.Error = nil
+	req.Response.BodyBytes = nil
+	req.Response.BodySize = 0
+	req.Response.Truncated = false
+	req.Response.CacheStatus = ""
+
+	// clear the error body of the previous attempt,
+	// so its fields don't remain in the error body of the next attempt
+	if errorBody := reflect.ValueOf(req.Response.ErrorBody); errorBody.Kind() == reflect.Pointer && !errorBody.IsNil() {
+		errorBody.Elem().SetZero()
+	}
+}
+
+// sleepContext waits for the given duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package vrest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo_retry(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("unexpected request body %q", body)
+		}
+
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetRetryPolicy(policy)
+
+	req := c.NewRequest().SetBody("payload")
+	if err := req.DoPut("/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 3 || req.Attempt != 3 {
+		t.Fatalf("unexpected attempts: calls %d, req.Attempt %d", calls.Load(), req.Attempt)
+	}
+}
+
+func TestDo_retryExhausted(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetRetryPolicy(policy)
+
+	if err := c.NewRequest().DoGet("/"); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls.Load() != int32(policy.MaxAttempts) {
+		t.Fatalf("unexpected calls: %d", calls.Load())
+	}
+
+	calls.Store(0)
+	if err := c.NewRequest().DoPost("/"); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("POST must not be retried, calls: %d", calls.Load())
+	}
+}
+
+func TestDo_retryResetsErrorBody(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if calls.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"code":"first","detail":"only in the first response"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":"second"}`))
+	}))
+	defer ts.Close()
+
+	type errorBody struct {
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	}
+
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 2
+	policy.InitialBackoff = time.Millisecond
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetRetryPolicy(policy)
+
+	body := &errorBody{}
+	if err := c.NewRequest().SetResponseErrorBody(body).DoGet("/"); err == nil {
+		t.Fatal("expected error")
+	}
+	if *body != (errorBody{Code: "second"}) {
+		t.Fatalf("error body of the first attempt was not reset: %+v", body)
+	}
+}
+
+func TestDo_retryAfterReauthenticate(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") != "Bearer token-2":
+			w.WriteHeader(http.StatusUnauthorized)
+		case calls.Add(1) == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 2
+	policy.InitialBackoff = time.Millisecond
+	c := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		SetTokenGetter(&testTokenGetter{}).
+		SetReauthMode(ReauthOnUnauthorized).
+		SetRetryPolicy(policy)
+
+	// the replay with the new token doesn't use up the retry
+	req := c.NewRequest()
+	if err := req.DoGet("/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !req.Reauthenticated || req.Attempt != 3 {
+		t.Fatalf("unexpected attempts: Reauthenticated %v, req.Attempt %d", req.Reauthenticated, req.Attempt)
+	}
+}
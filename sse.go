@@ -0,0 +1,220 @@
+package vrest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSSEReconnectDelay = 3 * time.Second
+
+var ErrSSEInvalidContentType = errors.New("sse response is not text/event-stream")
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is the last event ID sent by the server.
+	ID string
+
+	// Event is the event type. It defaults to "message".
+	Event string
+
+	// Data is the event data. Multiple data lines are joined with "\n".
+	Data string
+
+	// Retry is the reconnect delay requested by the server with this
+	// event, or 0 if the event has no retry field. The delay is
+	// applied as soon as it is received, even without an event.
+	Retry time.Duration
+}
+
+// SSEConfig configures server-sent event streams.
+type SSEConfig struct {
+	// Reconnect enables automatic reconnects if the stream ends or fails.
+	// The last received event ID is sent in the Last-Event-ID header.
+	// Responses that are not successful, 204 No Content and responses
+	// that are not text/event-stream are not reconnected.
+	Reconnect bool
+
+	// ReconnectDelay is the wait time before reconnecting.
+	// The server can change it with the retry field.
+	// It defaults to 3 seconds.
+	ReconnectDelay time.Duration
+
+	// MaxReconnects limits the number of consecutive reconnects
+	// without receiving an event. If it is 0, there is no limit.
+	MaxReconnects int
+}
+
+// SetSSEConfig sets the configuration for DoEvents.
+func (req *Request) SetSSEConfig(cfg SSEConfig) *Request {
+	req.SSE = cfg
+	return req
+}
+
+// DoEvents sends the request with the GET method and returns an iterator
+// over the server-sent events of the text/event-stream response.
+// Each connection goes through the whole request pipeline, including
+// token acquisition, middlewares and traces.
+// The iteration stops when the request context is done, when the stream
+// ends without reconnect, when the server responds with 204 No Content
+// or on the first error, which is yielded.
+func (req *Request) DoEvents(path string) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		template := req.clone()
+		delay := req.SSE.ReconnectDelay
+		if delay <= 0 {
+			delay = defaultSSEReconnectDelay
+		}
+
+		conn := req
+		var lastEventID string
+		reconnects := 0
+
+		for {
+			receivedEvents, err := conn.streamEvents(path, lastEventID, func(event Event) bool {
+				lastEventID = event.ID
+				return yield(event, nil)
+			}, func(retry time.Duration) {
+				delay = retry
+			})
+			if errors.Is(err, errSSEStopped) || errors.Is(err, errSSENoContent) {
+				return
+			}
+
+			if receivedEvents {
+				reconnects = 0
+			}
+			if !conn.shouldReconnect(err, reconnects) {
+				if err != nil {
+					yield(Event{}, err)
+				}
+				return
+			}
+
+			reconnects++
+			if sleepErr := sleepContext(req.Context, delay); sleepErr != nil {
+				yield(Event{}, sleepErr)
+				return
+			}
+			conn = template.clone()
+		}
+	}
+}
+
+var (
+	errSSEStopped   = errors.New("sse stream stopped by caller")
+	errSSENoContent = errors.New("sse stream closed by server with 204 No Content")
+)
+
+// streamEvents connects and calls onEvent for each received event
+// and onRetry for each retry field. It reports whether at least one
+// event was received.
+func (req *Request) streamEvents(path, lastEventID string, onEvent func(Event) bool, onRetry func(time.Duration)) (bool, error) {
+	req.SetHeader("Accept", "text/event-stream")
+	req.SetHeader("Cache-Control", "no-cache")
+	req.SetHeaderIf(lastEventID != "", "Last-Event-ID", lastEventID)
+
+	var stream io.ReadCloser
+	if err := req.SetResponseBody(&stream).Do(http.MethodGet, path); err != nil {
+		return false, err
+	}
+	defer req.Client.closeRawResponse(req)
+
+	// the server tells the client to stop reconnecting with 204 No Content
+	if req.Response.StatusCode() == http.StatusNoContent {
+		return false, errSSENoContent
+	}
+	if mediaType, _, _ := mime.ParseMediaType(req.Response.ContentType()); mediaType != "text/event-stream" {
+		return false, fmt.Errorf("%w: %s %s returned %q", ErrSSEInvalidContentType, req.Method, req.Raw.URL, req.Response.ContentType())
+	}
+
+	received := false
+	err := readEvents(stream, lastEventID, func(event Event) bool {
+		received = true
+		return onEvent(event)
+	}, onRetry)
+	return received, err
+}
+
+func (req *Request) shouldReconnect(err error, reconnects int) bool {
+	if !req.SSE.Reconnect || req.Context.Err() != nil {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) || errors.Is(err, ErrSSEInvalidContentType) {
+		return false
+	}
+
+	return req.SSE.MaxReconnects <= 0 || reconnects < req.SSE.MaxReconnects
+}
+
+// readEvents parses the event stream and calls onEvent for each event and
+// onRetry for each valid retry field, as soon as it is parsed.
+// It returns errSSEStopped if onEvent returns false and nil
+// if the stream ended.
+func readEvents(r io.Reader, lastEventID string, onEvent func(Event) bool, onRetry func(time.Duration)) error {
+	reader := bufio.NewReader(r)
+	event := Event{ID: lastEventID}
+	var data strings.Builder
+	hasData := false
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			// an empty line dispatches the event
+			if hasData {
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				event.Data = data.String()
+				if !onEvent(event) {
+					return errSSEStopped
+				}
+			}
+			event = Event{ID: event.ID}
+			data.Reset()
+			hasData = false
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			// comment
+		case "event":
+			event.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				event.ID = value
+			}
+		case "retry":
+			if millis, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(millis) * time.Millisecond
+				onRetry(event.Retry)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package vrest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequest_DoEvents(t *testing.T) {
+	var connections atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch connections.Add(1) {
+		case 1:
+			_, _ = fmt.Fprint(w, ": comment\n\nid: 1\nevent: progress\ndata: 10\ndata: %\n\nretry: 1\ndata: 20\n\n")
+		case 2:
+			if got := r.Header.Get("Last-Event-ID"); got != "1" {
+				t.Errorf("unexpected Last-Event-ID %q", got)
+			}
+			_, _ = fmt.Fprint(w, "id: 2\nevent: done\ndata: 100\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := NewWithClient(ts.Client()).
+		SetBaseURL(ts.URL).
+		NewRequestWithContext(ctx).
+		SetSSEConfig(SSEConfig{Reconnect: true, ReconnectDelay: time.Millisecond})
+
+	var events []Event
+	for event, err := range req.DoEvents("/events") {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		events = append(events, event)
+		if event.Event == "done" {
+			break
+		}
+	}
+
+	want := []Event{
+		{ID: "1", Event: "progress", Data: "10\n%"},
+		{ID: "1", Event: "message", Data: "20", Retry: time.Millisecond},
+		{ID: "2", Event: "done", Data: "100"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("unexpected events:\ngot:  %+v\nwant: %+v", events, want)
+	}
+}
+
+func TestRequest_DoEvents_stop(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		status          int
+		wantErr         error
+		wantConnections int32
+	}{
+		{name: "no content", contentType: "text/event-stream", status: http.StatusNoContent, wantConnections: 2},
+		{name: "wrong content type", contentType: "application/json", status: http.StatusOK, wantErr: ErrSSEInvalidContentType, wantConnections: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connections atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if connections.Add(1) == 1 && tt.status == http.StatusNoContent {
+					// the retry field applies without a dispatched event
+					_, _ = fmt.Fprint(w, "retry: 1\n")
+					return
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req := NewWithClient(ts.Client()).
+				SetBaseURL(ts.URL).
+				NewRequestWithContext(ctx).
+				SetSSEConfig(SSEConfig{Reconnect: true, ReconnectDelay: time.Hour})
+
+			var errs []error
+			for _, err := range req.DoEvents("/events") {
+				errs = append(errs, err)
+			}
+
+			if tt.wantErr == nil && len(errs) > 0 || tt.wantErr != nil && (len(errs) != 1 || !errors.Is(errs[0], tt.wantErr)) {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if connections.Load() != tt.wantConnections {
+				t.Fatalf("unexpected number of connections: %d", connections.Load())
+			}
+		})
+	}
+}
@@ -1,10 +1,22 @@
 package vrest
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+const (
+	defaultTokenTimeout            = 30 * time.Second
+	defaultInteractiveTokenTimeout = 10 * time.Minute
+)
+
+var ErrTokenRefreshFailed = errors.New("token refresh failed")
+
 // Token is an interface that represents any kind of token.
 // Tokens are used with Bearer authentication.
 type Token interface {
@@ -22,48 +34,233 @@ type Token interface {
 type TokenGetter interface {
 	// GetToken returns a new or refreshed token.
 	// If a previous token exists, it is passed as oldToken.
+	// This is also the case if the previous token was
+	// rejected by the server.
 	GetToken(ctx context.Context, oldToken Token) (Token, error)
 }
 
-// getValidToken returns a valid token. If the current token is invalid, it will be refreshed.
-// This function is thread-safe.
-func (c *Client) getValidToken(ctx context.Context) (Token, error) {
-	token := c.token.Load()
-	if token != nil && !token.NeedsRefresh() {
-		return token, nil
-	}
+// TokenScope selects a token of the client's token cache.
+// The zero value selects the default token of the token getter.
+type TokenScope struct {
+	Scope    string
+	Audience string
+	Resource string
+}
+
+// ScopedTokenGetter is a TokenGetter that can get tokens for different
+// scopes. It is required for requests that use Request.SetTokenScope.
+// vrest caches one token per scope and makes sure, that GetScopedToken
+// is not called concurrently for the same scope.
+type ScopedTokenGetter interface {
+	TokenGetter
+
+	// GetScopedToken returns a new or refreshed token for the given scope.
+	// If a previous token exists for the scope, it is passed as oldToken.
+	GetScopedToken(ctx context.Context, scope TokenScope, oldToken Token) (Token, error)
+}
 
-	c.tokenMutex.Lock()
-	defer c.tokenMutex.Unlock()
+// ExpiringToken is a Token that knows when it expires.
+// Background token refresh requires tokens to implement it.
+type ExpiringToken interface {
+	Token
 
-	// double check after lock if another thread
+	// ExpiresAt returns the time when the token must no longer be used.
+	ExpiresAt() time.Time
+}
+
+// getValidToken returns a valid token entry for the scope. If the current
+// token is invalid, it will be refreshed. This function is thread-safe.
+// Concurrent callers for the same scope share a single refresh.
+func (c *Client) getValidToken(ctx context.Context, scope TokenScope) (*tokenEntry, error) {
+	return c.loadToken(ctx, scope, false)
+}
+
+// loadToken returns the token entry for the scope. It is refreshed
+// if it is invalid or if force is set.
+func (c *Client) loadToken(ctx context.Context, scope TokenScope, force bool) (*tokenEntry, error) {
+	slot := c.tokens.slot(scope)
+	if entry := slot.entry.Load(); !force && entry.isValid() {
+		return entry, nil
+	}
+
+	c.tokens.mu.Lock()
+	// double check after lock if another goroutine
 	// already refreshed the token
-	token = c.token.Load()
-	if token != nil && !token.NeedsRefresh() {
-		return token, nil
+	if entry := slot.entry.Load(); !force && entry.isValid() {
+		c.tokens.mu.Unlock()
+		return entry, nil
+	}
+
+	call := slot.call
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		slot.call = call
+		refreshCtx, cancel := c.refreshContext(ctx)
+		go c.refreshToken(refreshCtx, cancel, scope, slot, call)
+	}
+	c.tokens.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.entry, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
+}
 
-	newToken, err := c.TokenGetter.GetToken(ctx, token)
-	if err != nil {
-		return nil, err
+// refreshContext returns the context of a token refresh. The refresh is shared
+// by all goroutines waiting for the token, so it must not be canceled with the
+// request that started it. Only the background refresher is stopped by Close.
+func (c *Client) refreshContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !isBackgroundRefresh(ctx) {
+		ctx = context.WithoutCancel(ctx)
 	}
-	c.token.Store(newToken)
-	return newToken, nil
+	return context.WithTimeout(ctx, c.tokenTimeout())
 }
 
-type atomicToken struct {
-	value atomic.Value
+// interactiveTokenGetter is implemented by token getters
+// that may need user interaction to get a token.
+type interactiveTokenGetter interface {
+	interactive() bool
 }
 
-func (t *atomicToken) Load() Token {
-	if val := t.value.Load(); val != nil {
-		if token, ok := val.(Token); ok {
-			return token
+// tokenTimeout returns the timeout of a token refresh. Users need
+// more time for interactive grants, like the device code grant.
+func (c *Client) tokenTimeout() time.Duration {
+	if getter, ok := c.TokenGetter.(interactiveTokenGetter); ok && getter.interactive() {
+		return cmp.Or(c.TokenTimeout, defaultInteractiveTokenTimeout)
+	}
+	return cmp.Or(c.TokenTimeout, defaultTokenTimeout)
+}
+
+// refreshToken gets a new token from the token getter and stores it in the slot.
+// The result is shared with all goroutines waiting for the call.
+func (c *Client) refreshToken(ctx context.Context, cancel context.CancelFunc, scope TokenScope, slot *tokenSlot, call *tokenCall) {
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			call.entry = nil
+			call.err = fmt.Errorf("%w: token getter panicked: %v", ErrTokenRefreshFailed, r)
+		}
+
+		c.tokens.mu.Lock()
+		if call.err == nil {
+			slot.entry.Store(call.entry)
+			c.startTokenRefresher(scope, slot)
+		}
+		slot.call = nil
+		c.tokens.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	var oldToken Token
+	if entry := slot.entry.Load(); entry != nil {
+		oldToken = entry.token
+	}
+
+	newToken := c.loadStoredToken(ctx, scope, oldToken)
+	if newToken != nil && newToken.NeedsRefresh() {
+		// the stored token expired, but its refresh token may still be valid
+		oldToken, newToken = newToken, nil
+	}
+	if newToken == nil {
+		var err error
+		if newToken, err = c.getToken(ctx, scope, oldToken); err != nil {
+			call.err = err
+			return
 		}
+		c.saveToken(ctx, scope, newToken)
 	}
-	return nil
+
+	call.entry = &tokenEntry{token: newToken, obtainedAt: c.now()}
+}
+
+// getToken calls the token getter for the given scope.
+func (c *Client) getToken(ctx context.Context, scope TokenScope, oldToken Token) (Token, error) {
+	if scope == (TokenScope{}) {
+		return c.TokenGetter.GetToken(ctx, oldToken)
+	}
+
+	scopedGetter, ok := c.TokenGetter.(ScopedTokenGetter)
+	if !ok {
+		return nil, fmt.Errorf("%w: token getter %T does not support token scopes", ErrInvalidRequest, c.TokenGetter)
+	}
+	return scopedGetter.GetScopedToken(ctx, scope, oldToken)
+}
+
+// invalidateToken marks the given token entry of the scope as invalid,
+// so the next call to getValidToken gets a new token. If the token was
+// already replaced by another goroutine, nothing happens.
+func (c *Client) invalidateToken(scope TokenScope, entry *tokenEntry) {
+	slot := c.tokens.slot(scope)
+
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+
+	if entry != nil && slot.entry.Load() == entry {
+		slot.entry.Store(&tokenEntry{token: entry.token, obtainedAt: entry.obtainedAt, invalidated: true})
+	}
+}
+
+// tokenEntry holds a token and whether it was rejected by the server.
+type tokenEntry struct {
+	token       Token
+	obtainedAt  time.Time
+	invalidated bool
+}
+
+func (e *tokenEntry) isValid() bool {
+	return e != nil && e.token != nil && !e.invalidated && !e.token.NeedsRefresh()
 }
 
-func (t *atomicToken) Store(token Token) {
-	t.value.Store(token)
+// tokenCache holds one token slot per scope. slotsMu guards the slots map,
+// the tokens of the slots are read without locking. mu guards the refresh
+// calls and refreshers of the slots.
+type tokenCache struct {
+	mu sync.Mutex
+
+	slotsMu sync.RWMutex
+	slots   map[TokenScope]*tokenSlot
+}
+
+// tokenSlot holds the current token of a scope and the running refresh call.
+type tokenSlot struct {
+	entry atomic.Pointer[tokenEntry]
+
+	// call is the running refresh call, guarded by tokenCache.mu.
+	call *tokenCall
+
+	// refreshing is set while a background refresher runs for
+	// the slot, guarded by tokenCache.mu.
+	refreshing bool
+}
+
+// tokenCall is a refresh call shared by all goroutines waiting for a token.
+type tokenCall struct {
+	done  chan struct{}
+	entry *tokenEntry
+	err   error
+}
+
+// slot returns the slot of the scope and creates it, if it doesn't exist.
+func (tc *tokenCache) slot(scope TokenScope) *tokenSlot {
+	tc.slotsMu.RLock()
+	slot, ok := tc.slots[scope]
+	tc.slotsMu.RUnlock()
+	if ok {
+		return slot
+	}
+
+	tc.slotsMu.Lock()
+	defer tc.slotsMu.Unlock()
+
+	if slot, ok = tc.slots[scope]; !ok {
+		if tc.slots == nil {
+			tc.slots = make(map[TokenScope]*tokenSlot)
+		}
+		slot = &tokenSlot{}
+		tc.slots[scope] = slot
+	}
+	return slot
 }
@@ -0,0 +1,204 @@
+package vrest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testToken string
+
+func (t testToken) Token() string {
+	return string(t)
+}
+
+func (t testToken) NeedsRefresh() bool {
+	return false
+}
+
+type testTokenGetter struct {
+	calls atomic.Int32
+}
+
+func (g *testTokenGetter) GetToken(_ context.Context, _ Token) (Token, error) {
+	return testToken("token-" + strconv.Itoa(int(g.calls.Add(1)))), nil
+}
+
+func TestDo_reauthenticate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		mode          ReauthMode
+		wantErr       bool
+		wantGetTokens int32
+	}{
+		{name: "on unauthorized", mode: ReauthOnUnauthorized, wantGetTokens: 2},
+		{name: "on invalid token", mode: ReauthOnInvalidToken, wantGetTokens: 2},
+		{name: "disabled", mode: ReauthDisabled, wantErr: true, wantGetTokens: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getter := &testTokenGetter{}
+			c := NewWithClient(ts.Client()).
+				SetBaseURL(ts.URL).
+				SetTokenGetter(getter).
+				SetReauthMode(tt.mode)
+
+			req := c.NewRequest()
+			err := req.DoGet("/")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if getter.calls.Load() != tt.wantGetTokens {
+				t.Fatalf("unexpected number of token requests: %d", getter.calls.Load())
+			}
+			if req.Reauthenticated != !tt.wantErr {
+				t.Fatalf("unexpected Reauthenticated: %v", req.Reauthenticated)
+			}
+
+			// the new token must be cached
+			if err == nil {
+				if err = c.NewRequest().DoGet("/"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if getter.calls.Load() != tt.wantGetTokens {
+					t.Fatalf("token was not cached, token requests: %d", getter.calls.Load())
+				}
+			}
+		})
+	}
+}
+
+type testScopedTokenGetter struct {
+	mu    sync.Mutex
+	calls map[TokenScope]int
+}
+
+func (g *testScopedTokenGetter) GetToken(ctx context.Context, oldToken Token) (Token, error) {
+	return g.GetScopedToken(ctx, TokenScope{}, oldToken)
+}
+
+func (g *testScopedTokenGetter) GetScopedToken(_ context.Context, scope TokenScope, _ Token) (Token, error) {
+	time.Sleep(10 * time.Millisecond)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls[scope]++
+	return testToken("token-" + scope.Scope), nil
+}
+
+func TestClient_getValidToken_scopes(t *testing.T) {
+	getter := &testScopedTokenGetter{calls: make(map[TokenScope]int)}
+	c := New().SetTokenGetter(getter)
+	scopes := []TokenScope{{}, {Scope: "orders"}, {Scope: "stock", Audience: "erp"}}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		for _, scope := range scopes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				entry, err := c.getValidToken(context.Background(), scope)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				if entry.token.Token() != "token-"+scope.Scope {
+					t.Errorf("unexpected token %s for scope %v", entry.token.Token(), scope)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, scope := range scopes {
+		if getter.calls[scope] != 1 {
+			t.Fatalf("unexpected number of token requests for scope %v: %d", scope, getter.calls[scope])
+		}
+	}
+}
+
+type blockingTokenGetter struct {
+	started chan struct{}
+	release chan struct{}
+	panics  bool
+}
+
+func (g *blockingTokenGetter) GetToken(ctx context.Context, _ Token) (Token, error) {
+	close(g.started)
+	select {
+	case <-g.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if g.panics {
+		panic("broken token getter")
+	}
+	return testToken("token"), nil
+}
+
+func TestClient_getValidToken_sharedRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		panics  bool
+		wantErr error
+	}{
+		{name: "leader context done"},
+		{name: "panicking getter", panics: true, wantErr: ErrTokenRefreshFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getter := &blockingTokenGetter{
+				started: make(chan struct{}),
+				release: make(chan struct{}),
+				panics:  tt.panics,
+			}
+			c := New().SetTokenGetter(getter)
+
+			// the leader gives up before the token is there
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			leaderErr := make(chan error, 1)
+			go func() {
+				_, err := c.getValidToken(ctx, TokenScope{})
+				leaderErr <- err
+			}()
+			<-getter.started
+
+			waiterErr := make(chan error, 1)
+			go func() {
+				_, err := c.getValidToken(context.Background(), TokenScope{})
+				waiterErr <- err
+			}()
+
+			if err := <-leaderErr; !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("unexpected leader error: %v", err)
+			}
+			close(getter.release)
+
+			select {
+			case err := <-waiterErr:
+				if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+					t.Fatalf("unexpected waiter error: %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("waiter was not released")
+			}
+		})
+	}
+}
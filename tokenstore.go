@@ -0,0 +1,264 @@
+package vrest
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidEncryptionKey = errors.New("invalid token store encryption key")
+
+// TokenStore persists tokens, so they can be reused by other clients or
+// after a restart. The client loads the token from the store before it
+// calls the TokenGetter and saves tokens it got from the TokenGetter.
+// Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// Load returns the stored token for the key.
+	// It returns nil and no error, if no token is stored.
+	Load(ctx context.Context, key string) (*StoredToken, error)
+
+	// Save stores the token for the key.
+	Save(ctx context.Context, key string, token *StoredToken) error
+}
+
+// StoredToken is the persisted form of a token.
+type StoredToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ValidUntil   time.Time `json:"valid_until"`
+}
+
+// StorableTokenGetter is a TokenGetter whose tokens can be persisted in a
+// TokenStore. The getter converts its tokens to and from the stored form,
+// so restored tokens have the same type as new tokens of the getter.
+// The OAuth, Azure managed identity and exec credential token getters implement it.
+type StorableTokenGetter interface {
+	TokenGetter
+
+	// StoreToken returns the persisted form of the token.
+	// It returns nil if the token can't be stored.
+	StoreToken(token Token) *StoredToken
+
+	// RestoreToken returns the token of the persisted form.
+	RestoreToken(stored *StoredToken) Token
+}
+
+// SetTokenStore sets the store used to persist tokens.
+// The token getter must implement StorableTokenGetter, otherwise the store
+// is not used and a warning is logged on every token refresh.
+// All built-in token getters implement it, except the Kubernetes token getter,
+// whose token file is already persisted.
+// The key identifies the tokens of the client's token getter in the store,
+// for example the OAuth client ID. Tokens of scopes other than the
+// default scope are stored with the scope appended to the key.
+func (c *Client) SetTokenStore(store TokenStore, key string) *Client {
+	c.TokenStore = store
+	c.TokenStoreKey = key
+	return c
+}
+
+// tokenStoreKey returns the store key for the scope.
+func (c *Client) tokenStoreKey(scope TokenScope) string {
+	if scope == (TokenScope{}) {
+		return c.TokenStoreKey
+	}
+	return strings.Join([]string{c.TokenStoreKey, scope.Scope, scope.Audience, scope.Resource}, "|")
+}
+
+// loadStoredToken returns the token from the token store, if it differs
+// from the old token. A stored token equal to the old token was either
+// rejected by the server or is due for a background refresh.
+// The returned token may need a refresh, but its refresh token can still
+// be valid, so the caller passes it to the token getter as the old token.
+func (c *Client) loadStoredToken(ctx context.Context, scope TokenScope, oldToken Token) Token {
+	if c.TokenStore == nil {
+		return nil
+	}
+	getter, ok := c.TokenGetter.(StorableTokenGetter)
+	if !ok {
+		c.logger.LogAttrs(ctx, slog.LevelWarn,
+			"token getter can't store tokens, token store is not used",
+			slog.String("token_getter", fmt.Sprintf("%T", c.TokenGetter)))
+		return nil
+	}
+
+	stored, err := c.TokenStore.Load(ctx, c.tokenStoreKey(scope))
+	if err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelWarn,
+			"failed to load token from token store",
+			slog.String("error", err.Error()))
+		return nil
+	}
+	if stored == nil || (oldToken != nil && oldToken.Token() == stored.AccessToken) {
+		return nil
+	}
+
+	return getter.RestoreToken(stored)
+}
+
+// saveToken saves the token in the token store, if the token getter can store it.
+func (c *Client) saveToken(ctx context.Context, scope TokenScope, token Token) {
+	getter, ok := c.TokenGetter.(StorableTokenGetter)
+	if c.TokenStore == nil || !ok {
+		return
+	}
+
+	stored := getter.StoreToken(token)
+	if stored == nil {
+		return
+	}
+
+	if err := c.TokenStore.Save(ctx, c.tokenStoreKey(scope), stored); err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelWarn,
+			"failed to save token in token store",
+			slog.String("error", err.Error()))
+	}
+}
+
+// MemoryTokenStore is a TokenStore that keeps tokens in memory.
+// It can share tokens between clients of the same process.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]StoredToken
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]StoredToken)}
+}
+
+// Load returns the stored token for the key.
+func (s *MemoryTokenStore) Load(_ context.Context, key string) (*StoredToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[key]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// Save stores the token for the key.
+func (s *MemoryTokenStore) Save(_ context.Context, key string, token *StoredToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[key] = *token
+	return nil
+}
+
+// FileTokenStore is a TokenStore that keeps one file per key in a directory.
+// Files are written atomically and are only readable by the current user.
+// If an encryption key is set, the files are encrypted with AES-GCM.
+type FileTokenStore struct {
+	Dir           string
+	EncryptionKey []byte
+}
+
+// NewFileTokenStore creates a token store for the given directory.
+// The directory is created when the first token is saved.
+func NewFileTokenStore(dir string) *FileTokenStore {
+	return &FileTokenStore{Dir: dir}
+}
+
+// SetEncryptionKey sets the AES key used to encrypt the token files.
+// The key must be 16, 24 or 32 bytes long.
+func (s *FileTokenStore) SetEncryptionKey(key []byte) *FileTokenStore {
+	s.EncryptionKey = key
+	return s
+}
+
+// Load returns the stored token for the key.
+func (s *FileTokenStore) Load(_ context.Context, key string) (*StoredToken, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.EncryptionKey != nil {
+		if data, err = s.decrypt(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var token StoredToken
+	if err = json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("invalid token file: %w", err)
+	}
+	return &token, nil
+}
+
+// Save stores the token for the key.
+func (s *FileTokenStore) Save(_ context.Context, key string, token *StoredToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	if s.EncryptionKey != nil {
+		if data, err = s.encrypt(data); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(s.path(key), data)
+}
+
+// path returns the file path for the key. The key is hashed,
+// so it can contain any characters.
+func (s *FileTokenStore) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (s *FileTokenStore) newAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEncryptionKey, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt returns nonce || ciphertext.
+func (s *FileTokenStore) encrypt(data []byte) ([]byte, error) {
+	aead, err := s.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (s *FileTokenStore) decrypt(data []byte) ([]byte, error) {
+	aead, err := s.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted token file")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,183 @@
+package vrest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "plain"},
+		{name: "encrypted", key: bytes.Repeat([]byte{1}, 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := filepath.Join(t.TempDir(), "tokens")
+			store := NewFileTokenStore(dir).SetEncryptionKey(tt.key)
+
+			token, err := store.Load(ctx, "client")
+			if err != nil || token != nil {
+				t.Fatalf("expected no token, got %v, %v", token, err)
+			}
+
+			want := &StoredToken{
+				AccessToken:  "access-token",
+				RefreshToken: "refresh-token",
+				ValidUntil:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			}
+			if err = store.Save(ctx, "client", want); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			token, err = store.Load(ctx, "client")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *token != *want {
+				t.Fatalf("unexpected token: got %+v, want %+v", token, want)
+			}
+
+			files, err := os.ReadDir(dir)
+			if err != nil || len(files) != 1 {
+				t.Fatalf("expected exactly one token file, got %v, %v", files, err)
+			}
+			info, err := files[0].Info()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Mode().Perm() != 0o600 {
+				t.Fatalf("unexpected file mode: %v", info.Mode())
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if encrypted := !bytes.Contains(data, []byte("access-token")); encrypted != (tt.key != nil) {
+				t.Fatalf("unexpected file content: %q", data)
+			}
+		})
+	}
+}
+
+// testStorableTokenGetter converts the tokens of testExpiringTokenGetter for the token store.
+type testStorableTokenGetter struct {
+	testExpiringTokenGetter
+}
+
+func (g *testStorableTokenGetter) StoreToken(token Token) *StoredToken {
+	oauthToken := token.(*OAuthToken)
+	return &StoredToken{AccessToken: oauthToken.AccessToken, ValidUntil: oauthToken.ValidUntil}
+}
+
+func (g *testStorableTokenGetter) RestoreToken(stored *StoredToken) Token {
+	return &OAuthToken{AccessToken: stored.AccessToken, ValidUntil: stored.ValidUntil}
+}
+
+func TestClient_tokenStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	getter := &testStorableTokenGetter{testExpiringTokenGetter{lifetime: time.Hour}}
+
+	first := New().SetTokenGetter(getter).SetTokenStore(store, "client")
+	if _, err := first.getValidToken(ctx, TokenScope{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// a second client, e.g. after a restart, reuses the stored token
+	second := New().SetTokenGetter(getter).SetTokenStore(store, "client")
+	entry, err := second.getValidToken(ctx, TokenScope{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if getter.calls.Load() != 1 {
+		t.Fatalf("stored token was not used, token requests: %d", getter.calls.Load())
+	}
+
+	// a rejected token is not loaded from the store again
+	second.invalidateToken(TokenScope{}, entry)
+	if _, err = second.getValidToken(ctx, TokenScope{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if getter.calls.Load() != 2 {
+		t.Fatalf("rejected token was loaded from store, token requests: %d", getter.calls.Load())
+	}
+}
+
+func TestClient_tokenStore_staleToken(t *testing.T) {
+	ts := newTestOAuthServer(t)
+	defer ts.Close()
+
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	stale := &StoredToken{AccessToken: "old", RefreshToken: "rt-1", ValidUntil: time.Now().Add(-time.Hour)}
+	if err := store.Save(ctx, "client", stale); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the expired token is not used, but its refresh token is
+	c := NewWithClient(ts.Client()).
+		SetOAuth(OAuthConfig{URL: ts.URL, GrantType: "client_credentials"}).
+		SetTokenStore(store, "client")
+	entry, err := c.getValidToken(ctx, TokenScope{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.token.Token() != "refreshed" {
+		t.Fatalf("stale token was not refreshed with its refresh token, got %s", entry.token.Token())
+	}
+	if stored, _ := store.Load(ctx, "client"); stored == nil || stored.RefreshToken != "rt-2" {
+		t.Fatalf("refreshed token was not stored: %+v", stored)
+	}
+}
+
+func TestClient_tokenStore_credentialGetters(t *testing.T) {
+	c := New()
+	getters := []TokenGetter{
+		&azureManagedIdentityGetter{client: c},
+		&execCredentialGetter{client: c},
+	}
+	for _, getter := range getters {
+		storable, ok := getter.(StorableTokenGetter)
+		if !ok {
+			t.Fatalf("%T doesn't implement StorableTokenGetter", getter)
+		}
+
+		validUntil := time.Now().Add(time.Hour)
+		stored := storable.StoreToken(&credentialToken{value: "token", validUntil: validUntil, clock: c.now})
+		token := storable.RestoreToken(stored)
+		if token.Token() != "token" || !token.(*credentialToken).ExpiresAt().Equal(validUntil) || token.NeedsRefresh() {
+			t.Fatalf("%T: unexpected restored token %+v", getter, token)
+		}
+	}
+}
+
+func TestClient_tokenStore_notStorable(t *testing.T) {
+	var logs bytes.Buffer
+	store := NewMemoryTokenStore()
+	c := New().
+		SetLogger(slog.New(slog.NewTextHandler(&logs, nil))).
+		SetTokenGetter(&testExpiringTokenGetter{lifetime: time.Hour}).
+		SetTokenStore(store, "client")
+	if _, err := c.getValidToken(context.Background(), TokenScope{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the client doesn't know how to restore tokens of the getter
+	if stored, _ := store.Load(context.Background(), "client"); stored != nil {
+		t.Fatalf("token of a getter without StorableTokenGetter was stored: %+v", stored)
+	}
+	if !strings.Contains(logs.String(), "token store is not used") {
+		t.Fatalf("unused token store was not logged: %q", logs.String())
+	}
+}
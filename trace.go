@@ -2,13 +2,16 @@ package vrest
 
 // TraceMaker defines an interface for handling traces of HTTP requests.
 // The interface is designed with Open Telemetry in mind.
-// vrest creates a new trace for each request.
+// vrest creates a new trace for each attempt of a request.
+// If a request is retried, Request.Attempt tells which attempt is traced.
 // A trace is only created, if the request could be built successfully.
 //
 // A TraceMaker/Trace has full access to all Request data, but it should
 // not modify anything. You can access the BodyBytes of the request and
 // response if they are available.
 // If the Request uses a Reader, BodyBytes will be nil.
+// For multipart requests, the metadata of the parts is available
+// in MultipartParts. Traces must not read the part readers.
 type TraceMaker interface {
 	// NewTrace is called just before a request is about to be executed
 	// by the HTTP client. NewTrace is NOT called, if a request could not
@@ -20,7 +23,8 @@ type TraceMaker interface {
 type Trace interface {
 	// OnAfterRequest is called just after a request was executed.
 	// It is called any time, even if the request/response was not
-	// successful.
+	// successful. Response.CacheStatus and Request.CircuitState are
+	// set by the HTTP middlewares and are available here.
 	OnAfterRequest(req *Request)
 
 	// End is called with defer, just after the trace has been created.